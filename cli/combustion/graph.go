@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+
+	"github.com/src-d/combustion"
+)
+
+type GraphCommand struct {
+	*Command
+	Format string `short:"f" long:"format" default:"dot" choice:"dot" choice:"json" description:"output format"`
+}
+
+func (c *GraphCommand) Execute(args []string) error {
+	if err := c.init(args); err != nil {
+		return err
+	}
+
+	var graphs []*combustion.Graph
+	for _, file := range c.files {
+		cfg, err := c.load(file, combustion.AllowMissing(), combustion.ImportsOnly())
+		if err != nil {
+			return err
+		}
+
+		graphs = append(graphs, cfg.Graph())
+	}
+
+	g := combustion.NewGraph(graphs...)
+	if c.Format == "json" {
+		return g.WriteJSON(os.Stdout)
+	}
+
+	return g.WriteDot(os.Stdout)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/combustion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphCommandExample(t *testing.T) {
+	dir, err := filepath.Abs("../../example")
+	assert.NoError(t, err)
+
+	cmd := &GraphCommand{Command: &Command{}, Format: "json"}
+	output, err := captureStdout(func() error {
+		return cmd.Execute([]string{dir})
+	})
+
+	assert.NoError(t, err)
+
+	var g combustion.Graph
+	assert.NoError(t, json.Unmarshal(output, &g))
+
+	var edges []string
+	for _, e := range g.Edges {
+		from, _ := filepath.Rel(dir, e.From)
+		to, _ := filepath.Rel(dir, e.To)
+		edges = append(edges, from+" -> "+to)
+	}
+
+	assert.Equal(t, []string{
+		"install-reboot.yaml -> includes/installer.yaml",
+		"includes/installer.yaml -> includes/installer-service.yaml",
+		"install-shutdown.yaml -> includes/installer.yaml",
+	}, edges)
+}
+
+// captureStdout returns what is written to the stdout while fn is executed
+func captureStdout(fn func() error) ([]byte, error) {
+	f, err := ioutil.TempFile("", "combustion-stdout")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	err = fn()
+	os.Stdout = stdout
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(f.Name())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/src-d/combustion"
@@ -12,11 +13,48 @@ import (
 const AppName = "combustion"
 
 func main() {
-	parser := flags.NewNamedParser(AppName, flags.Default)
-	parser.Command, _ = parser.AddCommand(AppName, "", "Options:", &Command{})
+	parser := flags.NewNamedParser(AppName, flags.HelpFlag|flags.PassDoubleDash)
+	parser.Usage = "[OPTIONS] <input>... | <command>"
+
+	cmd := &Command{}
+	parser.Command, _ = parser.AddCommand(AppName, "", "Options:", cmd)
+	parser.Command.SubcommandsOptional = true
+	parser.AddCommand("graph", "Prints the import graph",
+		"Prints the import graph of the files in the input folders, in DOT or JSON format.",
+		&GraphCommand{Command: cmd},
+	)
+
+	parser.AddCommand("vars", "Prints the variables used by each file",
+		"Prints the variables referenced by every file in the input folders and its imports, "+
+			"and if they are provided, inherited, defaulted, missing or used at runtime.",
+		&VarsCommand{Command: cmd},
+	)
+
+	secrets, _ := parser.AddCommand("secrets", "Manages the secrets store",
+		"Manages the secrets store given with --secrets, the values are read from the stdin.",
+		&struct{}{},
+	)
+
+	secrets.AddCommand("add", "Adds a secret", "Adds a new secret to the store.",
+		&SecretsAddCommand{Command: cmd},
+	)
+
+	secrets.AddCommand("rotate", "Replaces a secret", "Replaces the value of an existing secret.",
+		&SecretsRotateCommand{Command: cmd},
+	)
+
+	secrets.AddCommand("list", "Lists the secrets", "Prints the names of the secrets in the store.",
+		&SecretsListCommand{Command: cmd},
+	)
 
 	_, err := parser.Parse()
 	if err != nil {
+		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+			fmt.Println(e.Message)
+			os.Exit(0)
+		}
+
+		printError(err)
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrCommandRequired {
 			parser.WriteHelp(os.Stdout)
 		}
@@ -25,17 +63,40 @@ func main() {
 	}
 }
 
+// printError prints the error to stderr, the errors of the files with the
+// trace of the imports
+func printError(err error) {
+	if e, ok := err.(*combustion.ErrConfig); ok {
+		fmt.Fprintln(os.Stderr, e.Trace())
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, err)
+}
+
 type Command struct {
-	Output string `short:"o" long:"output" description:"output folder"`
-	Input  struct {
-		Folders []string `positional-arg-name:"input" description:"List of folders to process"`
-	} `positional-args:"yes"`
+	Output    string   `short:"o" long:"output" description:"output folder"`
+	Cache     string   `long:"cache" description:"cache folder for remote imports"`
+	Offline   bool     `long:"offline" description:"fails if a remote import is not cached"`
+	Include   []string `short:"I" long:"include" description:"search path for lib: imports, can be repeated"`
+	Values    []string `long:"values" description:"YAML file with values for the root files, can be repeated"`
+	Set       []string `long:"set" description:"value for the root files as key=value, can be repeated"`
+	EnvPrefix string   `long:"env-prefix" description:"reads values from the environment variables with this prefix"`
+	Secrets   string   `long:"secrets" description:"secrets store used by the secret function"`
+	KeyFile   string   `long:"secrets-key-file" description:"file with the key of the secrets store, by default is read from $COMBUSTION_SECRETS_KEY"`
+	Root      string   `long:"root" description:"directory the file urls can't point outside of"`
+	HostFiles bool     `long:"allow-host-files" description:"allows file+host urls, absolute paths of the host"`
+	PinRemote bool     `long:"pin-remote" description:"fetches the remote storage files and sets their verification hash"`
+	Inline    bool     `long:"inline-remote" description:"fetches the remote storage files and includes their content"`
 
-	files []string
+	folders []string
+	files   []string
+	values  combustion.Values
+	store   *combustion.SecretStore
 }
 
 func (c *Command) Execute(args []string) error {
-	if err := c.findAllFiles(); err != nil {
+	if err := c.init(args); err != nil {
 		return err
 	}
 
@@ -48,6 +109,119 @@ func (c *Command) Execute(args []string) error {
 	return nil
 }
 
+// init configures combustion with the options and finds all the files in the
+// given folders
+func (c *Command) init(folders []string) error {
+	if c.Cache != "" {
+		dir, err := filepath.Abs(c.Cache)
+		if err != nil {
+			return err
+		}
+
+		combustion.DefaultCache.Dir = dir
+	}
+
+	combustion.DefaultCache.Offline = c.Offline
+
+	for i, path := range c.Include {
+		if strings.Contains(path, "://") {
+			return fmt.Errorf("invalid search path %q, only local directories are supported", path)
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		c.Include[i] = abs
+	}
+
+	if err := c.loadValues(); err != nil {
+		return err
+	}
+
+	if c.Secrets != "" {
+		if err := c.openSecrets(); err != nil {
+			return err
+		}
+	}
+
+	c.folders = folders
+	return c.findAllFiles()
+}
+
+// loadValues reads the values of the root files, the values from the
+// environment are overridden by the values files, in order, and those by the
+// --set ones
+func (c *Command) loadValues() error {
+	c.values = make(combustion.Values)
+	if c.EnvPrefix != "" {
+		// the key of the secrets store is never a value, even with its prefix
+		v, err := combustion.NewValuesFromEnv(c.EnvPrefix, combustion.SecretsKeyEnv)
+		if err != nil {
+			return err
+		}
+
+		c.values.Merge(v)
+	}
+
+	for _, file := range c.Values {
+		// the FileSystem is rooted at /, so the paths should be absolute
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+
+		v, err := combustion.NewValuesFromFile(file)
+		if err != nil {
+			return err
+		}
+
+		c.values.Merge(v)
+	}
+
+	for _, expr := range c.Set {
+		if err := c.values.Set(expr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openSecrets opens the secrets store, with the key from the key file or from
+// the environment
+func (c *Command) openSecrets() error {
+	if c.Secrets == "" {
+		return fmt.Errorf("missing secrets store, use --secrets")
+	}
+
+	key := []byte(os.Getenv(combustion.SecretsKeyEnv))
+	if c.KeyFile != "" {
+		file, err := filepath.Abs(c.KeyFile)
+		if err != nil {
+			return err
+		}
+
+		key, err = combustion.ReadSecretKey(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(key) == 0 {
+		return fmt.Errorf("missing secrets key, use --secrets-key-file or $%s", combustion.SecretsKeyEnv)
+	}
+
+	file, err := filepath.Abs(c.Secrets)
+	if err != nil {
+		return err
+	}
+
+	c.store, err = combustion.NewSecretStore(file, key)
+	return err
+}
+
 func (c *Command) findAllFiles() error {
 	if err := c.findFiles("/*.yaml"); err != nil {
 		return err
@@ -61,7 +235,7 @@ func (c *Command) findAllFiles() error {
 }
 
 func (c *Command) findFiles(pattern string) error {
-	for _, folder := range c.Input.Folders {
+	for _, folder := range c.folders {
 		results, err := filepath.Glob(folder + pattern)
 		if err != nil {
 			return err
@@ -74,7 +248,7 @@ func (c *Command) findFiles(pattern string) error {
 }
 
 func (c *Command) render(file string) error {
-	cfg, err := combustion.NewConfigFromFile(file, nil)
+	cfg, err := c.load(file)
 	if err != nil {
 		return err
 	}
@@ -93,5 +267,39 @@ func (c *Command) render(file string) error {
 		return err
 	}
 
-	return err
+	if len(r.Entries) != 0 {
+		fmt.Fprint(os.Stderr, r)
+	}
+
+	return nil
+}
+
+func (c *Command) load(file string, extra ...combustion.Option) (*combustion.Config, error) {
+	opts := append([]combustion.Option{combustion.SearchPaths(c.Include...)}, extra...)
+	if c.store != nil {
+		opts = append(opts, combustion.Secrets(c.store))
+	}
+
+	if c.Root != "" {
+		root, err := filepath.Abs(c.Root)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, combustion.Root(root))
+	}
+
+	if c.HostFiles {
+		opts = append(opts, combustion.AllowHostFiles())
+	}
+
+	if c.PinRemote {
+		opts = append(opts, combustion.PinRemoteFiles())
+	}
+
+	if c.Inline {
+		opts = append(opts, combustion.InlineRemoteFiles())
+	}
+
+	return combustion.NewConfigFromFile(file, c.values, opts...)
 }
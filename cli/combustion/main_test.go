@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/src-d/combustion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandLoadValuesSecretsKey(t *testing.T) {
+	os.Setenv("COMBUSTION_CHANNEL", "stable")
+	os.Setenv(combustion.SecretsKeyEnv, "a: [b")
+	defer os.Unsetenv("COMBUSTION_CHANNEL")
+	defer os.Unsetenv(combustion.SecretsKeyEnv)
+
+	c := &Command{EnvPrefix: "COMBUSTION_"}
+	assert.NoError(t, c.loadValues())
+	assert.Equal(t, combustion.Values{"channel": "stable"}, c.values)
+}
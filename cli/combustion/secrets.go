@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type SecretsAddCommand struct {
+	*Command
+}
+
+func (c *SecretsAddCommand) Execute(args []string) error {
+	return c.set(args, false)
+}
+
+type SecretsRotateCommand struct {
+	*Command
+}
+
+func (c *SecretsRotateCommand) Execute(args []string) error {
+	return c.set(args, true)
+}
+
+type SecretsListCommand struct {
+	*Command
+}
+
+func (c *SecretsListCommand) Execute(args []string) error {
+	if err := c.openSecrets(); err != nil {
+		return err
+	}
+
+	for _, name := range c.store.Names() {
+		fmt.Println(name)
+	}
+
+	return nil
+}
+
+// set reads the secret from the stdin and stores it with the name given in
+// args, exists requires the secret to be, or not to be, already in the store
+func (c *Command) set(args []string, exists bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of the secret")
+	}
+
+	if err := c.openSecrets(); err != nil {
+		return err
+	}
+
+	name := args[0]
+	if c.store.Has(name) != exists {
+		if exists {
+			return fmt.Errorf("secret %q not found, use add", name)
+		}
+
+		return fmt.Errorf("secret %q already exists, use rotate", name)
+	}
+
+	secret, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	c.store.Set(name, strings.TrimSuffix(string(secret), "\n"))
+	return c.store.Save()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/src-d/combustion"
+)
+
+type VarsCommand struct {
+	*Command
+	Format string `short:"f" long:"format" default:"text" choice:"text" choice:"json" description:"output format"`
+}
+
+func (c *VarsCommand) Execute(args []string) error {
+	if err := c.init(args); err != nil {
+		return err
+	}
+
+	var vars []*combustion.FileVars
+	for _, file := range c.files {
+		cfg, err := c.load(file, combustion.AllowMissing())
+		if err != nil {
+			return err
+		}
+
+		vars = append(vars, cfg.Vars()...)
+	}
+
+	if c.Format == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(vars)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, v := range vars {
+		fmt.Fprintln(w, v.File+formatValues(v.Values))
+		for _, variable := range v.Vars {
+			fmt.Fprintf(w, "  %s\t%s\n", variable.Name, variable.Status)
+		}
+	}
+
+	return w.Flush()
+}
+
+func formatValues(v combustion.Values) string {
+	if len(v) == 0 {
+		return ""
+	}
+
+	var values []string
+	for k, value := range v {
+		values = append(values, fmt.Sprintf("%s=%v", k, value))
+	}
+
+	sort.Strings(values)
+	return " (" + strings.Join(values, ", ") + ")"
+}
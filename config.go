@@ -2,6 +2,7 @@ package combustion
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,8 +10,9 @@ import (
 	"net/url"
 	"path/filepath"
 	"reflect"
-	"regexp"
+	"strings"
 	"text/template"
+	"unicode/utf8"
 
 	"github.com/coreos/container-linux-config-transpiler/config"
 	"github.com/coreos/container-linux-config-transpiler/config/types"
@@ -18,6 +20,7 @@ import (
 	"github.com/coreos/ignition/config/validate"
 	"github.com/coreos/ignition/config/validate/report"
 	"github.com/src-d/combustion/transpiler"
+	"github.com/vincent-petithory/dataurl"
 	"gopkg.in/src-d/go-billy.v2"
 	"gopkg.in/src-d/go-billy.v2/osfs"
 	"gopkg.in/yaml.v1"
@@ -27,43 +30,69 @@ import (
 var FileSystem billy.Filesystem = osfs.New("/")
 
 type Config struct {
-	Imports map[string]Values `yaml:"import,omitempty"`
-	Output  string            `yaml:"output,omitempty"`
-	Type    string            `yaml:"type,omitempty"`
+	Imports Imports `yaml:"-"`
+	Params  Params  `yaml:"-"`
+	Trees   []*Tree `yaml:"-"`
+	Output  string  `yaml:"output,omitempty"`
+	Type    string  `yaml:"type,omitempty"`
 	types.Config
 
-	dir  string // dir where the config is located
-	name string // config name
+	dir    string // dir where the config is located
+	name   string // config name
+	values Values // values used to interpolate the config
+
+	options *options
+	graph   *Graph
+	vars    []*FileVars
+	uses    map[string]bool // values used by the config or inherited by its imports
+	report  report.Report   // warnings found resolving the imports
 }
 
 // NewConfigFromFile opens the given file and calls NewConfig with the given
-// values
-func NewConfigFromFile(filename string, values map[string]string) (*Config, error) {
+// values and options
+func NewConfigFromFile(filename string, values Values, opts ...Option) (*Config, error) {
 	file, err := FileSystem.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewConfig(file, filename, values)
+	return NewConfig(file, filename, values, opts...)
 }
 
 // NewConfig returns a new Config unmarshaling the r content interpolated with
 // the given values. A dir, should be provided to be able to read and resolve
-// all the includes
-func NewConfig(r io.Reader, filename string, values map[string]string) (*Config, error) {
-	c, err := newConfig(r, filename, values)
+// all the includes. The values are inherited by the imports, so they aren't
+// checked against the params of the file.
+func NewConfig(r io.Reader, filename string, values Values, opts ...Option) (*Config, error) {
+	c, err := newConfig(r, filename, values, nil, newOptions(opts))
 	if err != nil {
 		return nil, err
 	}
 
-	return c, c.resolve()
+	if err := c.resolve(); err != nil {
+		return c, err
+	}
+
+	if c.options.importsOnly {
+		return c, nil
+	}
+
+	return c, c.interpolateLate()
 }
 
-func newConfig(r io.Reader, filename string, values map[string]string) (*Config, error) {
+// newConfig returns a new Config, the explicit values are the values given
+// directly to the file, not inherited from the importing files
+func newConfig(r io.Reader, filename string, values, explicit Values, o *options) (*Config, error) {
 	c := &Config{}
-	c.dir, c.name = filepath.Split(filename)
+	c.dir, c.name = splitPath(filename)
+	c.options = o
+
+	y, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := c.Unmarshal(r, values); err != nil {
+	if err := c.unmarshal(y, values, explicit); err != nil {
 		return nil, err
 	}
 
@@ -71,44 +100,129 @@ func newConfig(r io.Reader, filename string, values map[string]string) (*Config,
 }
 
 // Unmarshal unmarshal the r content into Config, the content is interpolated
-// using the given values
-func (c *Config) Unmarshal(r io.Reader, values map[string]string) error {
+// using the given values, after being validated against the declared params,
+// and then the late actions are executed, without resolving the imports
+func (c *Config) Unmarshal(r io.Reader, values Values) error {
 	y, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
-	y, err = c.interpolate(y, values)
+	if err := c.unmarshal(y, values, values); err != nil {
+		return err
+	}
+
+	return c.interpolateLate()
+}
+
+// unmarshal interpolates and decodes the content, any error is returned as
+// an ErrConfig
+func (c *Config) unmarshal(y []byte, values, explicit Values) error {
+	if err := c.doUnmarshal(y, values, explicit); err != nil {
+		if e, ok := err.(*ErrConfig); ok {
+			e.Values = explicit
+			return e
+		}
+
+		return newErrConfig(c.path(), explicit, err)
+	}
+
+	return nil
+}
+
+func (c *Config) doUnmarshal(y []byte, values, explicit Values) error {
+	var err error
+	c.Params, err = newParams(y)
+	if err != nil {
+		return err
+	}
+
+	c.values, err = c.Params.validate(values, explicit)
+	if err != nil && (c.options == nil || !c.options.allowMissing) {
+		return err
+	}
+
+	content := y
+	y, err = c.interpolate(y, c.values)
 	if err != nil {
 		return err
 	}
 
+	vars := newFileVars(c.path(), values, explicit, c.values)
+	if err := vars.parse(content, c.options); err != nil {
+		return err
+	}
+
+	c.vars = []*FileVars{vars}
+
 	if err := yaml.Unmarshal(y, &c); err != nil {
 		return err
 	}
 
+	if err := c.Params.check(y); err != nil {
+		return err
+	}
+
 	if err := yaml.Unmarshal(y, &c.Config); err != nil {
 		return err
 	}
 
-	return c.loadLocalFiles()
+	if err := c.unmarshalImports(y); err != nil {
+		return err
+	}
+
+	c.Trees, err = newTrees(y)
+	if err != nil {
+		return err
+	}
+
+	if c.options != nil && c.options.importsOnly {
+		return nil
+	}
+
+	if err := c.loadLocalFiles(); err != nil {
+		return err
+	}
+
+	return c.loadTrees()
 }
 
-var translateInterpolation = regexp.MustCompile(`{%(.+?)%}`)
+func (c *Config) unmarshalImports(y []byte) error {
+	var err error
+	c.Imports, err = newImports(y)
+	return err
+}
 
 func (c *Config) interpolate(content []byte, v Values) ([]byte, error) {
-	name := filepath.Join(c.dir, c.name)
-	t, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	return c.interpolateFile(c.path(), content, v, true)
+}
+
+// interpolateFile interpolates the content of the given file, the config or
+// any templated file included by it. The late actions are only translated when
+// late is true, in the source of the config, in any other file they are
+// written as is.
+func (c *Config) interpolateFile(filename string, content []byte, v Values, late bool) ([]byte, error) {
+	s, content, err := newSyntax(content)
 	if err != nil {
 		return nil, err
 	}
 
+	src, m := s.translate(content, late)
+	t, err := template.New(filename).
+		Delims(s.Left, s.Right).
+		Funcs(c.options.funcs()).
+		Option(c.options.missingKey()).
+		Parse(string(src))
+	if err != nil {
+		return nil, m.errConfig(filename, err)
+	}
+
 	buf := bytes.NewBuffer(nil)
 	if err := t.Execute(buf, v); err != nil {
-		return nil, err
+		return nil, m.errConfig(filename, err)
 	}
 
-	return translateInterpolation.ReplaceAll(buf.Bytes(), []byte("{{$1}}")), nil
+	return buf.Bytes(), nil
 }
 
 func (c *Config) loadLocalFiles() error {
@@ -120,7 +234,7 @@ func (c *Config) loadLocalFiles() error {
 		c.Storage.Files[i] = f
 	}
 
-	return nil
+	return c.loadLocalContents()
 }
 
 func (c *Config) loadLocalFile(f *types.File) error {
@@ -129,28 +243,124 @@ func (c *Config) loadLocalFile(f *types.File) error {
 		return err
 	}
 
-	if u.Scheme != "file" {
+	if isRemote(f.Contents.Remote.Url) {
+		return c.loadRemoteFile(f)
+	}
+
+	if !isLocalScheme(u.Scheme) {
 		return nil
 	}
 
-	raw, err := c.doLoadLocalFile(u)
+	raw, err := c.readLocalURL(u)
 	if err != nil {
 		return err
 	}
 
-	f.Contents.Inline = raw
-	f.Contents.Remote.Url = ""
+	return setLocalContents(&f.Contents, raw)
+}
+
+// loadRemoteFile fetches the remote file, when the remote files are pinned or
+// inlined, setting its verification hash or including its content. If the
+// file already has a hash, the content is validated against it.
+func (c *Config) loadRemoteFile(f *types.File) error {
+	if c.options == nil || (!c.options.pinRemote && !c.options.inlineRemote) {
+		return nil
+	}
+
+	url := strings.TrimSpace(f.Contents.Remote.Url)
+	h := &f.Contents.Remote.Verification.Hash
+
+	var content []byte
+	var hash string
+	var err error
+	if h.Sum != "" {
+		if h.Function != "sha512" {
+			return fmt.Errorf("unsupported hash function %q of %q, expected sha512", h.Function, url)
+		}
+
+		hash = hashPrefix + h.Sum
+		content, err = DefaultCache.Get(url, hash)
+	} else {
+		content, hash, err = DefaultCache.Fetch(url)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c.options.inlineRemote {
+		f.Contents.Remote.Verification = types.Verification{}
+		return setLocalContents(&f.Contents, content)
+	}
+
+	h.Function, h.Sum = "sha512", strings.TrimPrefix(hash, hashPrefix)
 	return nil
 }
 
-func (c *Config) doLoadLocalFile(u *url.URL) (string, error) {
-	filename := filepath.Join(c.dir, u.Path[1:])
-	f, err := FileSystem.Open(filename)
+// loadLocalContents replaces the local file urls used as contents of the
+// systemd units and dropins, the networkd units and the ssh authorized keys,
+// by the content of the files
+func (c *Config) loadLocalContents() error {
+	var err error
+	for i := range c.Systemd.Units {
+		u := &c.Systemd.Units[i]
+		if u.Contents, err = c.localContents(u.Contents); err != nil {
+			return err
+		}
+
+		for j := range u.Dropins {
+			d := &u.Dropins[j]
+			if d.Contents, err = c.localContents(d.Contents); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range c.Networkd.Units {
+		u := &c.Networkd.Units[i]
+		if u.Contents, err = c.localContents(u.Contents); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.Passwd.Users {
+		u := &c.Passwd.Users[i]
+
+		var keys []string
+		for _, key := range u.SSHAuthorizedKeys {
+			if !hasLocalScheme(strings.TrimSpace(key)) {
+				keys = append(keys, key)
+				continue
+			}
+
+			content, err := c.localContents(key)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, authorizedKeys(content)...)
+		}
+
+		u.SSHAuthorizedKeys = keys
+	}
+
+	return nil
+}
+
+// localContents returns the content of the file if s is a local file url,
+// otherwise s is returned
+func (c *Config) localContents(s string) (string, error) {
+	trimmed := strings.TrimSpace(s)
+	if !hasLocalScheme(trimmed) {
+		return s, nil
+	}
+
+	u, err := url.Parse(trimmed)
 	if err != nil {
-		return "", fmt.Errorf("error opening %q: %s", filename, err)
+		return "", err
 	}
 
-	content, err := ioutil.ReadAll(f)
+	content, err := c.readLocalURL(u)
 	if err != nil {
 		return "", err
 	}
@@ -158,37 +368,237 @@ func (c *Config) doLoadLocalFile(u *url.URL) (string, error) {
 	return string(content), nil
 }
 
-func (c *Config) resolve() error {
-	return c.doResolve(c.dir, make(stack, 0))
+// readLocalURL returns the content of the file referenced by a file, file+host
+// or file+tmpl url
+func (c *Config) readLocalURL(u *url.URL) ([]byte, error) {
+	if isRemote(c.dir) || isGit(c.dir) {
+		return nil, fmt.Errorf("file urls are not supported in remote or git file %q", c.name)
+	}
+
+	filename, err := c.options.localPath(c.dir, u)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.doLoadLocalFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == templateFileScheme {
+		return c.loadTemplateFile(filename, raw)
+	}
+
+	return raw, nil
+}
+
+// authorizedKeys returns the keys of an authorized_keys file, ignoring the
+// empty lines and the comments
+func authorizedKeys(content string) []string {
+	var keys []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+
+	return keys
+}
+
+func (c *Config) doLoadLocalFile(filename string) ([]byte, error) {
+	f, err := FileSystem.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %q: %s", filename, err)
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
 }
 
-func (c *Config) doResolve(dir string, s stack) error {
-	for file, values := range c.Imports {
-		fullpath := filepath.Join(dir, string(file))
-		if s.In(fullpath) {
-			return &ErrCircularDependency{s, fullpath}
+// loadTemplateFile interpolates the content of a file+tmpl url with the values
+// of the config, adding its variables to the ones of the config
+func (c *Config) loadTemplateFile(filename string, content []byte) ([]byte, error) {
+	if len(c.vars) != 0 {
+		if err := c.vars[0].parse(content, c.options); err != nil {
+			return nil, err
 		}
+	}
 
-		f, err := FileSystem.Open(fullpath)
+	return c.interpolateFile(filename, content, c.values, false)
+}
+
+// inlineLimit is the max size of the local files included inline
+const inlineLimit = 64 * 1024
+
+// setLocalContents sets the content of a local file, inline if it's text and
+// small enough, otherwise as a base64 data url, gzip compressed if it reduces
+// the size. If the file already has a compression, the content is considered
+// already compressed and is included as is.
+func setLocalContents(fc *types.FileContents, content []byte) error {
+	fc.Remote.Url = ""
+	if fc.Remote.Compression == "" && utf8.Valid(content) && len(content) <= inlineLimit {
+		fc.Inline = string(content)
+		return nil
+	}
+
+	if fc.Remote.Compression == "" {
+		compressed, err := gzipBytes(content)
 		if err != nil {
 			return err
 		}
 
-		src, err := newConfig(f, fullpath, values)
+		if len(compressed) < len(content) {
+			content = compressed
+			fc.Remote.Compression = "gzip"
+		}
+	}
+
+	fc.Remote.Url = dataurl.EncodeBytes(content)
+	return nil
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resolution holds the state shared by the resolution of a config tree
+type resolution struct {
+	imported map[string][]*importedFile // by full path
+	graph    *Graph
+	report   report.Report
+}
+
+func (c *Config) resolve() error {
+	c.graph = newGraph()
+	c.graph.addNode(c.path(), c.Output)
+
+	res := &resolution{
+		imported: make(map[string][]*importedFile),
+		graph:    c.graph,
+	}
+
+	err := c.doResolve(c.dir, stack{c.path()}, res)
+	c.report = res.report
+	return err
+}
+
+func (c *Config) doResolve(dir string, s stack, res *resolution) error {
+	c.uses = c.ownUses()
+	for _, imp := range c.Imports {
+		ok, err := c.options.enabled(imp)
 		if err != nil {
 			return err
 		}
 
-		if err := src.doResolve(filepath.Dir(fullpath), append(s, fullpath)); err != nil {
+		if !ok {
+			continue
+		}
+
+		files, err := imp.expand(dir, c.path(), c.options)
+		if err != nil {
 			return err
 		}
 
-		c.append(src)
+		for _, fullpath := range files {
+			if err := c.doResolveImport(imp, fullpath, s, res); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+func (c *Config) doResolveImport(
+	imp *Import, fullpath string, s stack, res *resolution,
+) error {
+	if s.In(fullpath) {
+		return &ErrCircularDependency{s, fullpath}
+	}
+
+	res.graph.addNode(fullpath, "")
+	res.graph.addEdge(c.path(), fullpath, imp.Values, imp.When != "")
+
+	values := c.values.inherit(imp.Values)
+	if !imp.Multiple {
+		for _, f := range res.imported[fullpath] {
+			if f.matches(values, imp.Values) {
+				c.checkUses(imp, f.config, fullpath, res)
+				return nil
+			}
+		}
+	}
+
+	r, err := imp.open(fullpath)
+	if err != nil {
+		return err
+	}
+
+	src, err := newConfig(r, fullpath, values, imp.Values, c.options)
+	if err != nil {
+		return c.importError(err)
+	}
+
+	err = src.doResolve(src.dir, append(s, fullpath), res)
+	if err != nil {
+		return c.importError(err)
+	}
+
+	res.imported[fullpath] = append(res.imported[fullpath], &importedFile{
+		config:   src,
+		values:   values,
+		explicit: imp.Values,
+	})
+
+	c.checkUses(imp, src, fullpath, res)
+	c.append(src)
+	c.vars = append(c.vars, src.vars...)
+	return nil
+}
+
+// importError adds the config to the stack of an ErrConfig returned by one of
+// its imports
+func (c *Config) importError(err error) error {
+	if e, ok := err.(*ErrConfig); ok {
+		e.Stack = append(stack{c.path()}, e.Stack...)
+	}
+
+	return err
+}
+
+// Graph returns the import graph of the config, built when the imports are
+// resolved
+func (c *Config) Graph() *Graph {
+	return c.graph
+}
+
+// Vars returns the variables referenced by the config and all its imports, in
+// the order they are imported
+func (c *Config) Vars() []*FileVars {
+	return c.vars
+}
+
+// path returns the full path of the config
+func (c *Config) path() string {
+	fullpath, err := joinPath(c.dir, c.name)
+	if err != nil {
+		return filepath.Join(c.dir, c.name)
+	}
+
+	return fullpath
+}
+
 func (c *Config) append(src *Config) {
 	c.Config = Append(c.Config, src.Config)
 }
@@ -220,6 +630,7 @@ func (c *Config) Render(w io.Writer) (r report.Report, err error) {
 		content, r, err = c.marshalToFuze()
 	}
 
+	r.Merge(c.report)
 	if err != nil {
 		return r, err
 	}
@@ -263,8 +674,6 @@ func (c *Config) marshalToCloudConfig() ([]byte, report.Report, error) {
 
 }
 
-// Values interpolation values to replace on the Config
-type Values map[string]string
 type stack []string
 
 func (s stack) In(filename string) bool {
@@ -2,7 +2,12 @@ package combustion
 
 import (
 	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/coreos/container-linux-config-transpiler/config/types"
@@ -48,7 +53,345 @@ func TestNewConfig(t *testing.T) {
 	}
 
 	sort.Strings(names)
-	assert.EqualValues(t, []string{"bar", "baz", "foo", "qux", "qux"}, names)
+	assert.EqualValues(t, []string{"bar", "baz", "foo", "qux"}, names)
+}
+
+func TestConfigResolveMultiple(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/multiple/foo.yaml",
+		"import:\n  - file: qux.yaml\n    multiple: true\n\nsystemd:\n  units:\n   - name: foo",
+	}, {
+		"fixtures/multiple/bar.yaml",
+		"import:\n  - file: qux.yaml\n    multiple: true\n\nsystemd:\n  units:\n   - name: bar",
+	}, {
+		"fixtures/multiple/qux.yaml",
+		"systemd:\n  units:\n   - name: qux",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - multiple/foo.yaml\n" +
+		"  - multiple/bar.yaml\n" +
+		"  - multiple/qux.yaml\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo", "qux", "bar", "qux"}, unitNames(c))
+}
+
+func TestConfigResolveDiamond(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/diamond/reboot.yaml",
+		"import:\n  - shared.yaml\n  - action.yaml\n  - all.yaml\n" +
+			"systemd:\n  units:\n   - name: {{ .action }}",
+	}, {
+		"fixtures/diamond/poweroff.yaml",
+		"import:\n  - shared.yaml\n  - action.yaml\n  - all.yaml\n" +
+			"systemd:\n  units:\n   - name: {{ .action }}",
+	}, {
+		"fixtures/diamond/all.yaml",
+		"systemd:\n  units:\n   - name: 'all {{ toJson . }}'",
+	}, {
+		"fixtures/diamond/shared.yaml",
+		"systemd:\n  units:\n   - name: shared {{ .channel }}",
+	}, {
+		"fixtures/diamond/action.yaml",
+		"systemd:\n  units:\n   - name: action {{ .action }}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: diamond/reboot.yaml\n" +
+		"    values:\n" +
+		"      action: reboot\n" +
+		"  - file: diamond/poweroff.yaml\n" +
+		"    values:\n" +
+		"      action: poweroff\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"channel": "stable"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{
+		"reboot", "shared stable", "action reboot",
+		`all {"action":"reboot","channel":"stable"}`,
+		"poweroff", "action poweroff",
+		`all {"action":"poweroff","channel":"stable"}`,
+	}, unitNames(c))
+}
+
+func TestConfigResolveOrder(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/order/a.yaml",
+		"systemd:\n  units:\n   - name: a",
+	}, {
+		"fixtures/order/b.yaml",
+		"systemd:\n  units:\n   - name: b",
+	}, {
+		"fixtures/order/c.yaml",
+		"systemd:\n  units:\n   - name: c",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - order/c.yaml\n" +
+		"  - file: order/a.yaml\n" +
+		"  - order/b.yaml\n" +
+		"",
+	)
+
+	for i := 0; i < 10; i++ {
+		c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+		assert.NoError(t, err)
+
+		assert.EqualValues(t, []string{"c", "a", "b"}, unitNames(c))
+	}
+}
+
+func TestConfigResolveOrderMap(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  order/c.yaml:\n" +
+		"  order/a.yaml:\n" +
+		"  order/b.yaml:\n" +
+		"",
+	)
+
+	for i := 0; i < 10; i++ {
+		c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+		assert.NoError(t, err)
+
+		assert.EqualValues(t, []string{"a", "b", "c"}, unitNames(c))
+	}
+}
+
+func TestConfigResolveGlob(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/glob-import/units/b.yaml",
+		"systemd:\n  units:\n   - name: b {{.name}}",
+	}, {
+		"fixtures/glob-import/units/a.yaml",
+		"systemd:\n  units:\n   - name: a {{.name}}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: glob-import/units/*.yaml\n" +
+		"    values:\n" +
+		"      name: foo\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"a foo", "b foo"}, unitNames(c))
+}
+
+func TestConfigResolveGlobSelf(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/glob-self/index.yaml",
+		"import:\n  - '*.yaml'\nsystemd:\n  units:\n   - name: index",
+	}, {
+		"fixtures/glob-self/a.yaml",
+		"systemd:\n  units:\n   - name: a",
+	}})
+
+	c, err := NewConfigFromFile("fixtures/glob-self/index.yaml", nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"index", "a"}, unitNames(c))
+}
+
+func TestConfigResolveWhen(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/when/worker.yaml",
+		"systemd:\n  units:\n   - name: worker",
+	}, {
+		"fixtures/when/master.yaml",
+		"systemd:\n  units:\n   - name: master",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: when/worker.yaml\n" +
+		"    when: '{{ eq .role \"worker\" }}'\n" +
+		"  - file: when/master.yaml\n" +
+		"    when: '{{ eq .role \"master\" }}'\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"role": "worker"})
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"worker"}, unitNames(c))
+}
+
+func TestConfigResolveWhenInvalid(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: when/worker.yaml\n" +
+		"    when: foo\n" +
+		"",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.Error(t, err)
+
+	// the condition is rendered once, with the file, the values are not executed
+	input = []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: when/worker.yaml\n" +
+		"    when: '{{ .enabled }}'\n" +
+		"",
+	)
+
+	_, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"enabled": "{{ true }}"})
+	assert.Error(t, err)
+}
+
+func TestConfigResolveLibrary(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/lib/a/foo.yaml",
+		"import:\n  - qux.yaml\nsystemd:\n  units:\n   - name: foo a",
+	}, {
+		"fixtures/lib/a/qux.yaml",
+		"systemd:\n  units:\n   - name: qux a",
+	}, {
+		"fixtures/lib/b/foo.yaml",
+		"systemd:\n  units:\n   - name: foo b",
+	}, {
+		"fixtures/lib/b/bar.yaml",
+		"systemd:\n  units:\n   - name: bar b",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - lib:foo.yaml\n" +
+		"  - lib:bar.yaml\n" +
+		"",
+	)
+
+	c, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/inline.yaml", nil,
+		SearchPaths("fixtures/lib/a", "fixtures/lib/b"),
+	)
+
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo a", "qux a", "bar b"}, unitNames(c))
+}
+
+func TestConfigResolveLibraryMissing(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - lib:missing.yaml\n" +
+		"",
+	)
+
+	_, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/inline.yaml", nil,
+		SearchPaths("fixtures/lib/a"),
+	)
+
+	assert.Error(t, err)
+}
+
+func TestConfigResolveLibraryNotLocal(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - lib:foo.yaml\n" +
+		"",
+	)
+
+	_, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/inline.yaml", nil,
+		SearchPaths("git+file:///srv/modules//lib"),
+	)
+
+	assert.EqualError(t, err, ""+
+		"invalid search path \"git+file:///srv/modules//lib\", "+
+		"only local directories are supported",
+	)
+}
+
+func TestConfigResolveInherit(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/inherit/foo.yaml",
+		"import:\n  - file: bar.yaml\n    values:\n      channel: beta\n" +
+			"systemd:\n  units:\n   - name: foo {{.channel}}",
+	}, {
+		"fixtures/inherit/bar.yaml",
+		"systemd:\n  units:\n   - name: bar {{.channel}} {{.version}}",
+	}, {
+		"fixtures/inherit/private.yaml",
+		"systemd:\n  units:\n   - name: private {{._password}}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - inherit/foo.yaml\n" +
+		"",
+	)
+
+	values := Values{"channel": "stable", "version": "1.0", "_password": "foo"}
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", values)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo stable", "bar beta 1.0"}, unitNames(c))
+
+	input = []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - inherit/private.yaml\n" +
+		"",
+	)
+
+	_, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", values)
+	assert.Error(t, err)
+}
+
+func TestConfigResolveTypedValues(t *testing.T) {
+	WriteFixture("fixtures/typed/foo.yaml", ""+
+		"systemd:\n"+
+		"  units:\n"+
+		"{{ range .units }}"+
+		"   - name: {{ . }}\n"+
+		"     enable: {{ $.enable }}\n"+
+		"{{ end }}",
+	)
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: typed/foo.yaml\n" +
+		"    values:\n" +
+		"      units: [foo, bar]\n" +
+		"      enable: true\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo", "bar"}, unitNames(c))
+	for _, u := range c.Config.Systemd.Units {
+		assert.True(t, u.Enable)
+	}
 }
 
 func TestConfigResolveCircular(t *testing.T) {
@@ -64,6 +407,13 @@ func TestConfigResolveCircular(t *testing.T) {
 
 	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
 	assert.IsType(t, &ErrCircularDependency{}, err)
+
+	WriteFixture("fixtures/circular/root.yaml", "import:\n    qux.yaml:")
+	WriteFixture("fixtures/circular/qux.yaml", "import:\n    root.yaml:")
+
+	_, err = NewConfigFromFile("fixtures/circular/root.yaml", nil)
+	assert.IsType(t, &ErrCircularDependency{}, err)
+	assert.Equal(t, "fixtures/circular/root.yaml", err.(*ErrCircularDependency).File)
 }
 
 func TestConfigResolveInterpolate(t *testing.T) {
@@ -141,6 +491,193 @@ func TestConfigFixStorageFilesMissingFile(t *testing.T) {
 	assert.Nil(t, c)
 }
 
+func TestOptionsLocalPathSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "combustion-root")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "outside"), 0755))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "link")))
+
+	o := newOptions([]Option{Root(root)})
+	for _, c := range []struct {
+		url string
+		ok  bool
+	}{
+		{"file:///sub/foo.txt", true},
+		{"file:///link/foo.txt", false},
+		{"file:///link/missing/foo.txt", false},
+		{"file:///../outside/foo.txt", false},
+	} {
+		u, err := url.Parse(c.url)
+		assert.NoError(t, err)
+
+		_, err = o.localPath(root, u)
+		assert.Equal(t, c.ok, err == nil, c.url)
+	}
+}
+
+func TestConfigFixStorageFilesPaths(t *testing.T) {
+	WriteFixture("fixtures/paths/sub/foo.txt", "foo")
+	WriteFixture("fixtures/paths/bar.txt", "bar")
+	WriteFixture("/etc/combustion-test", "host")
+
+	for _, c := range []struct {
+		url     string
+		opts    []Option
+		content string
+	}{
+		{"file:///foo.txt", nil, "foo"},
+		{"file://foo.txt", nil, "foo"},
+		{"file:///../bar.txt", nil, "bar"},
+		{"file:///../bar.txt", []Option{Root("fixtures/paths")}, "bar"},
+		{"file:///../../inline.yaml", []Option{Root("fixtures/paths")}, ""},
+		{"file+host:///etc/combustion-test", nil, ""},
+		{"file+host:///etc/combustion-test", []Option{AllowHostFiles()}, "host"},
+		{"file+host://etc/combustion-test", []Option{AllowHostFiles()}, ""},
+	} {
+		input := []byte("" +
+			"---\n" +
+			"storage:\n" +
+			"  files:\n" +
+			"    - path: test\n" +
+			"      contents:\n" +
+			"        remote:\n" +
+			"          url: " + c.url + "\n" +
+			"",
+		)
+
+		cfg, err := NewConfig(bytes.NewBuffer(input), "fixtures/paths/sub/inline.yaml", nil, c.opts...)
+		if c.content == "" {
+			assert.Error(t, err, c.url)
+			continue
+		}
+
+		assert.NoError(t, err, c.url)
+		assert.Equal(t, c.content, cfg.Storage.Files[0].Contents.Inline, c.url)
+	}
+}
+
+func TestConfigFixStorageFilesBinary(t *testing.T) {
+	WriteFixture("fixtures/binary/text.txt", "foo")
+	WriteFixture("fixtures/binary/binary.bin", "\xff\xfe")
+	WriteFixture("fixtures/binary/big.txt", strings.Repeat("foo\n", inlineLimit))
+	WriteFixture("fixtures/binary/compressed.gz", "\x1f\x8b")
+
+	for _, c := range []struct {
+		url         string
+		compression string
+		inline      bool
+	}{
+		{"file:///text.txt", "", true},
+		{"file:///binary.bin", "", false},
+		{"file:///big.txt", "gzip", false},
+		{"file:///compressed.gz\n          compression: gzip", "gzip", false},
+	} {
+		input := []byte("" +
+			"---\n" +
+			"storage:\n" +
+			"  files:\n" +
+			"    - path: test\n" +
+			"      contents:\n" +
+			"        remote:\n" +
+			"          url: " + c.url + "\n" +
+			"",
+		)
+
+		cfg, err := NewConfig(bytes.NewBuffer(input), "fixtures/binary/inline.yaml", nil)
+		assert.NoError(t, err, c.url)
+
+		contents := cfg.Storage.Files[0].Contents
+		assert.Equal(t, c.compression, contents.Remote.Compression, c.url)
+		if c.inline {
+			assert.Equal(t, "foo", contents.Inline, c.url)
+			assert.Equal(t, "", contents.Remote.Url, c.url)
+			continue
+		}
+
+		assert.Equal(t, "", contents.Inline, c.url)
+		assert.True(t, strings.HasPrefix(contents.Remote.Url, "data:"), c.url)
+	}
+}
+
+func TestConfigFixStorageFilesTemplate(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/tmpl/kubelet.env",
+		"NAME={{ .name }}\nMAC={% .mac %}\n",
+	}, {
+		"fixtures/tmpl/foo.yaml",
+		"storage:\n  files:\n   - path: /etc/kubelet.env\n     filesystem: root\n" +
+			"     contents:\n       remote:\n         url: file+tmpl:///kubelet.env",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: tmpl/foo.yaml\n" +
+		"    values:\n" +
+		"      name: foo\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME=foo\nMAC={{ .mac }}\n", c.Storage.Files[0].Contents.Inline)
+	assert.Len(t, c.report.Entries, 0)
+
+	assert.Equal(t, []*Var{
+		{Name: "name", Status: VarProvided},
+		{Name: "mac", Status: VarRuntime},
+	}, c.Vars()[1].Vars)
+}
+
+func TestConfigLocalContents(t *testing.T) {
+	WriteFixtures([][]string{
+		{"fixtures/contents/foo.service", "[Service]\nExecStart=/foo\n"},
+		{"fixtures/contents/foo.conf", "[Service]\nEnvironment=FOO={{ .foo }}\n"},
+		{"fixtures/contents/eth0.network", "[Match]\nName=eth0\n"},
+		{"fixtures/contents/keys", "# admins\nssh-rsa AAAA foo\n\nssh-rsa BBBB bar\n"},
+	})
+
+	input := []byte("" +
+		"---\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"    - name: foo.service\n" +
+		"      contents: file:///foo.service\n" +
+		"      dropins:\n" +
+		"        - name: foo.conf\n" +
+		"          contents: file+tmpl:///foo.conf\n" +
+		"    - name: bar.service\n" +
+		"      contents: |\n" +
+		"        [Service]\n" +
+		"networkd:\n" +
+		"  units:\n" +
+		"    - name: eth0.network\n" +
+		"      contents: file:///eth0.network\n" +
+		"passwd:\n" +
+		"  users:\n" +
+		"    - name: core\n" +
+		"      ssh_authorized_keys:\n" +
+		"        - ssh-rsa CCCC qux\n" +
+		"        - file:///keys\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/contents/inline.yaml", Values{"foo": "bar"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "[Service]\nExecStart=/foo\n", c.Systemd.Units[0].Contents)
+	assert.Equal(t, "[Service]\nEnvironment=FOO=bar\n", c.Systemd.Units[0].Dropins[0].Contents)
+	assert.Equal(t, "[Service]\n", c.Systemd.Units[1].Contents)
+	assert.Equal(t, "[Match]\nName=eth0\n", c.Networkd.Units[0].Contents)
+	assert.Equal(t, []string{
+		"ssh-rsa CCCC qux", "ssh-rsa AAAA foo", "ssh-rsa BBBB bar",
+	}, c.Passwd.Users[0].SSHAuthorizedKeys)
+}
+
 func TestConfigRender(t *testing.T) {
 	input := []byte("" +
 		"---\n" +
@@ -214,3 +751,163 @@ func WriteFixture(path, content string) {
 		panic(err)
 	}
 }
+
+// unitNames returns the names of the systemd units of the config, in order
+func unitNames(c *Config) []string {
+	var names []string
+	for _, u := range c.Config.Systemd.Units {
+		names = append(names, string(u.Name))
+	}
+
+	return names
+}
+
+func TestConfigInterpolateLate(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/late/foo.yaml",
+		"systemd:\n  units:\n   - name: foo.service",
+	}, {
+		"fixtures/late/units.yaml",
+		"storage:\n  files:\n   - path: /etc/units\n     filesystem: root\n" +
+			"     contents:\n       inline: <% range .Systemd.Units %><% .Name %> <% end %>",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - late/units.yaml\n" +
+		"  - late/foo.yaml\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"   - name: {{ .name }}.service\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"name": "bar"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bar.service foo.service ", c.Config.Storage.Files[0].Contents.Inline)
+}
+
+func TestConfigInterpolateLateSource(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/late-source/index.erb",
+		"<%= @name %> <% .Systemd.Units %>\n",
+	}, {
+		"fixtures/late-source/units.tmpl",
+		"{{ .name }} <% .Systemd.Units %>\n",
+	}, {
+		"fixtures/late-source/erb.yaml",
+		"# combustion:late off\n" +
+			"# combustion:delims <% %>\n" +
+			"systemd:\n  units:\n   - name: <% .name %> {{ .name }}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - late-source/erb.yaml\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"   - name: '{{ .password }}'\n" +
+		"   - name: '{{ .action }}'\n" +
+		"   - name: \\<% .Output %> <% len .Systemd.Units %>\n" +
+		"storage:\n" +
+		"  files:\n" +
+		"   - path: /index.erb\n" +
+		"     filesystem: root\n" +
+		"     contents:\n" +
+		"       remote:\n" +
+		"         url: file:///late-source/index.erb\n" +
+		"   - path: /units\n" +
+		"     filesystem: root\n" +
+		"     contents:\n" +
+		"       remote:\n" +
+		"         url: file+tmpl:///late-source/units.tmpl\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{
+		"name":     "foo",
+		"password": "a<%b",
+		"action":   "<% .Systemd.Units %>",
+	})
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{
+		"a<%b", "<% .Systemd.Units %>", "<% .Output %> 4", "foo {{ .name }}",
+	}, unitNames(c))
+
+	assert.Equal(t, "<%= @name %> <% .Systemd.Units %>\n", c.Storage.Files[0].Contents.Inline)
+	assert.Equal(t, "foo <% .Systemd.Units %>\n", c.Storage.Files[1].Contents.Inline)
+}
+
+func TestConfigInterpolateLateError(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"   - name: <% .Missing %>\n" +
+		"",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.Error(t, err)
+}
+
+func TestConfigUnusedValues(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/unused/foo.yaml",
+		"import:\n  - file: bar.yaml\n    values:\n      channel: beta\n" +
+			"  - file: qux.yaml\n    when: '{{ .enabled }}'\n" +
+			"systemd:\n  units:\n   - name: foo {{ .action }}",
+	}, {
+		"fixtures/unused/bar.yaml",
+		"systemd:\n  units:\n   - name: bar {{ .channel }} {{ .version }}",
+	}, {
+		"fixtures/unused/all.yaml",
+		"systemd:\n  units:\n   - name: 'all {{ toJson . }}'",
+	}, {
+		"fixtures/unused/template.yaml",
+		"import:\n  - all.yaml\n" +
+			"systemd:\n  units:\n   - name: template",
+	}, {
+		"fixtures/unused/qux.yaml",
+		"params:\n  - name: declared\n" +
+			"systemd:\n  units:\n   - name: qux",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: unused/foo.yaml\n" +
+		"    values:\n" +
+		"      action: reboot\n" +
+		"      actoin: reboot\n" +
+		"      channel: stable\n" +
+		"      version: 1.0\n" +
+		"      enabled: true\n" +
+		"  - file: unused/qux.yaml\n" +
+		"    values:\n" +
+		"      declared: foo\n" +
+		"  - file: unused/all.yaml\n" +
+		"    values:\n" +
+		"      json: foo\n" +
+		"  - file: unused/template.yaml\n" +
+		"    values:\n" +
+		"      inherited: foo\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	var messages []string
+	for _, e := range c.report.Entries {
+		messages = append(messages, e.Message)
+	}
+
+	assert.Equal(t, []string{
+		`value "actoin" given to "fixtures/unused/foo.yaml" in "fixtures/inline.yaml" is never used`,
+		`value "channel" given to "fixtures/unused/foo.yaml" in "fixtures/inline.yaml" is never used`,
+	}, messages)
+}
@@ -0,0 +1,93 @@
+package combustion
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrConfig is an error interpolating or decoding a file, with the position of
+// the error in the file, when known, and the import stack
+type ErrConfig struct {
+	// Stack files importing File, from the root file to the direct parent
+	Stack stack
+	// File where the error happened
+	File string
+	// Line and Column of the error, zero when unknown
+	Line, Column int
+	// Rendered is true when Line refers to the interpolated content of File,
+	// like in the YAML errors, and not to its source
+	Rendered bool
+	// Values given to File by its parent in the import
+	Values Values
+	// Message of the error, without the position
+	Message string
+}
+
+var (
+	// templatePosition matches the position of the text/template errors, after
+	// the template name, like ":3:12: executing "foo.yaml" at <.foo>: "
+	templatePosition = regexp.MustCompile(`^:(\d+)(?::(\d+))?: (?:executing "[^"]*" )?`)
+	// yamlPosition matches the position of the YAML errors
+	yamlPosition = regexp.MustCompile(`^YAML error: line (\d+): `)
+)
+
+// newErrConfig returns an ErrConfig from an error of the given file, reading
+// the position from the template and YAML errors
+func newErrConfig(file string, values Values, err error) *ErrConfig {
+	e := &ErrConfig{File: file, Values: values, Message: err.Error()}
+
+	prefix := "template: " + file
+	if strings.HasPrefix(e.Message, prefix) {
+		if m := templatePosition.FindStringSubmatch(e.Message[len(prefix):]); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Column, _ = strconv.Atoi(m[2])
+			e.Message = e.Message[len(prefix)+len(m[0]):]
+		}
+
+		return e
+	}
+
+	if m := yamlPosition.FindStringSubmatch(e.Message); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Rendered = true
+		e.Message = e.Message[len(m[0]):]
+	}
+
+	return e
+}
+
+// Position returns the file with the line and column, if known, or with the
+// line of the interpolated content
+func (err *ErrConfig) Position() string {
+	switch {
+	case err.Line == 0:
+		return err.File
+	case err.Rendered:
+		return fmt.Sprintf("%s (rendered line %d)", err.File, err.Line)
+	case err.Column == 0:
+		return fmt.Sprintf("%s:%d", err.File, err.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", err.File, err.Line, err.Column)
+	}
+}
+
+func (err *ErrConfig) Error() string {
+	return fmt.Sprintf("%s: %s", err.Position(), err.Message)
+}
+
+// Trace returns the error followed by the values given to the file and the
+// files importing it, one per line, from the direct parent to the root file
+func (err *ErrConfig) Trace() string {
+	lines := []string{err.Error()}
+	if len(err.Values) != 0 {
+		lines = append(lines, "  with values "+strings.Replace(err.Values.label(), "\n", ", ", -1))
+	}
+
+	for i := len(err.Stack) - 1; i >= 0; i-- {
+		lines = append(lines, "  imported by "+err.Stack[i])
+	}
+
+	return strings.Join(lines, "\n")
+}
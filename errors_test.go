@@ -0,0 +1,100 @@
+package combustion
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewErrConfig(t *testing.T) {
+	for _, c := range []struct {
+		err      string
+		line     int
+		column   int
+		message  string
+		position string
+	}{{
+		`template: foo.yaml:3:12: executing "foo.yaml" at <.foo>: map has no entry for key "foo"`,
+		3, 12, `at <.foo>: map has no entry for key "foo"`, "foo.yaml:3:12",
+	}, {
+		`template: foo.yaml:2: function "foo" not defined`,
+		2, 0, `function "foo" not defined`, "foo.yaml:2",
+	}, {
+		"YAML error: line 4: did not find expected key",
+		4, 0, "did not find expected key", "foo.yaml (rendered line 4)",
+	}, {
+		`missing required value "foo"`,
+		0, 0, `missing required value "foo"`, "foo.yaml",
+	}} {
+		err := newErrConfig("foo.yaml", nil, fmt.Errorf("%s", c.err))
+		assert.Equal(t, c.line, err.Line, c.err)
+		assert.Equal(t, c.column, err.Column, c.err)
+		assert.Equal(t, c.message, err.Message, c.err)
+		assert.Equal(t, c.position, err.Position(), c.err)
+	}
+}
+
+func TestConfigErrConfig(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/errors/foo.yaml",
+		"import:\n  - file: bar.yaml\n    values:\n      channel: beta\n",
+	}, {
+		"fixtures/errors/bar.yaml",
+		"systemd:\n  units:\n   - name: {{ .channel }} {{ .version }}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - errors/foo.yaml\n" +
+		"",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.IsType(t, &ErrConfig{}, err)
+
+	e := err.(*ErrConfig)
+	assert.Equal(t, stack{"fixtures/inline.yaml", "fixtures/errors/foo.yaml"}, e.Stack)
+	assert.Equal(t, "fixtures/errors/bar.yaml", e.File)
+	assert.Equal(t, 3, e.Line)
+	assert.Equal(t, 29, e.Column)
+	assert.Equal(t, Values{"channel": "beta"}, e.Values)
+}
+
+func TestConfigErrConfigColumn(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"   - name: {% .mac %} \\<% <% .Output %> {{ .version }}\n" +
+		"",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.IsType(t, &ErrConfig{}, err)
+
+	e := err.(*ErrConfig)
+	assert.Equal(t, 4, e.Line)
+	assert.Equal(t, 43, e.Column)
+}
+
+func TestErrConfigTrace(t *testing.T) {
+	err := &ErrConfig{
+		Stack:   stack{"root.yaml", "foo.yaml"},
+		File:    "bar.yaml",
+		Line:    3,
+		Column:  29,
+		Values:  Values{"channel": "beta", "count": 2},
+		Message: "foo",
+	}
+
+	assert.Equal(t, ""+
+		"bar.yaml:3:29: foo\n"+
+		"  with values channel=beta, count=2\n"+
+		"  imported by foo.yaml\n"+
+		"  imported by root.yaml",
+		err.Trace(),
+	)
+}
@@ -0,0 +1,204 @@
+package combustion
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v1"
+)
+
+// funcs are the functions available in every template, the value being
+// processed is always the last argument, so they can be used in pipelines
+var funcs = template.FuncMap{
+	"default":   defaultValue,
+	"required":  required,
+	"quote":     quote,
+	"indent":    indent,
+	"nindent":   nindent,
+	"toYaml":    toYAML,
+	"toJson":    toJSON,
+	"b64enc":    b64enc,
+	"sha256sum": sha256sum,
+	"split":     split,
+	"join":      join,
+	"env":       env,
+	"upper":     func(v interface{}) string { return strings.ToUpper(toString(v)) },
+	"lower":     func(v interface{}) string { return strings.ToLower(toString(v)) },
+	"title":     func(v interface{}) string { return strings.Title(toString(v)) },
+	"trim":      func(v interface{}) string { return strings.TrimSpace(toString(v)) },
+	"snakecase": func(v interface{}) string { return joinWords(toString(v), "_") },
+	"kebabcase": func(v interface{}) string { return joinWords(toString(v), "-") },
+	"camelcase": camelcase,
+	"secret":    noSecret,
+}
+
+// defaultValue returns d if the value is missing or empty, since missing keys
+// are an error it should be used with index, {{ index . "foo" | default "bar" }}
+func defaultValue(d interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || isEmpty(v[0]) {
+		return d
+	}
+
+	return v[0]
+}
+
+// required returns an error with the given message if the value is empty
+func required(msg string, v interface{}) (interface{}, error) {
+	if isEmpty(v) {
+		return nil, fmt.Errorf("%s", msg)
+	}
+
+	return v, nil
+}
+
+// env returns the value of the environment variable, except for the key of the
+// secrets store, which would be written in plain text in the output
+func env(name string) (string, error) {
+	if name == SecretsKeyEnv {
+		return "", fmt.Errorf("environment variable %q is not readable", name)
+	}
+
+	return os.Getenv(name), nil
+}
+
+// noSecret is the secret function when no secrets store is configured
+func noSecret(name string) (string, error) {
+	return "", fmt.Errorf("secret %q requested, but no secrets store is configured", name)
+}
+
+func quote(v interface{}) string {
+	return strconv.Quote(toString(v))
+}
+
+// indent indents every line of the value with n spaces
+func indent(n int, v interface{}) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(toString(v), "\n", "\n"+pad, -1)
+}
+
+// nindent is like indent but adding a new line at the beginning
+func nindent(n int, v interface{}) string {
+	return "\n" + indent(n, v)
+}
+
+func toYAML(v interface{}) (string, error) {
+	y, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(y), "\n"), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(j), nil
+}
+
+func b64enc(v interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(toString(v)))
+}
+
+func sha256sum(v interface{}) string {
+	sum := sha256.Sum256([]byte(toString(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+func split(sep string, v interface{}) []string {
+	return strings.Split(toString(v), sep)
+}
+
+// join joins the elements of a list, of any type, with the separator
+func join(sep string, v interface{}) (string, error) {
+	list := reflect.ValueOf(v)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", v)
+	}
+
+	elems := make([]string, list.Len())
+	for i := range elems {
+		elems[i] = toString(list.Index(i).Interface())
+	}
+
+	return strings.Join(elems, sep), nil
+}
+
+func camelcase(v interface{}) string {
+	words := strings.Split(joinWords(toString(v), " "), " ")
+	for i, w := range words {
+		if i != 0 {
+			words[i] = strings.Title(w)
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// joinWords splits the value in lower case words, by case changes and any
+// non alphanumeric character, and joins them with the separator
+func joinWords(s, sep string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) != 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+
+	var prev rune
+	for _, r := range s {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+
+		prev = r
+	}
+
+	flush()
+	return strings.Join(words, sep)
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return value.Len() == 0
+	default:
+		return reflect.DeepEqual(v, reflect.Zero(value.Type()).Interface())
+	}
+}
@@ -0,0 +1,91 @@
+package combustion
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuncs(t *testing.T) {
+	os.Setenv("COMBUSTION_TEST", "foo")
+	defer os.Unsetenv("COMBUSTION_TEST")
+
+	values := Values{
+		"name":   "foo",
+		"empty":  "",
+		"keys":   []interface{}{"a", "b"},
+		"mounts": map[string]interface{}{"foo": "/bar"},
+		"lines":  "foo\nbar",
+		"count":  3,
+	}
+
+	for _, c := range [][]string{
+		{`{{ index . "missing" | default "bar" }}`, "bar"},
+		{`{{ .empty | default "bar" }}`, "bar"},
+		{`{{ .name | default "bar" }}`, "foo"},
+		{`{{ .name | required "name is required" }}`, "foo"},
+		{`{{ .name | quote }}`, `"foo"`},
+		{`{{ .count | quote }}`, `"3"`},
+		{`{{ .lines | indent 2 }}`, "  foo\n  bar"},
+		{`{{ .lines | nindent 2 }}`, "\n  foo\n  bar"},
+		{`{{ .mounts | toYaml }}`, "foo: /bar"},
+		{`{{ .keys | toJson }}`, `["a","b"]`},
+		{`{{ .name | b64enc }}`, "Zm9v"},
+		{`{{ .name | sha256sum }}`, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		{`{{ "a,b" | split "," | join "-" }}`, "a-b"},
+		{`{{ .keys | join "," }}`, "a,b"},
+		{`{{ env "COMBUSTION_TEST" }}`, "foo"},
+		{`{{ "foo bar" | upper }}`, "FOO BAR"},
+		{`{{ "FOO" | lower }}`, "foo"},
+		{`{{ "foo bar" | title }}`, "Foo Bar"},
+		{`{{ " foo " | trim }}`, "foo"},
+		{`{{ "fooBar baz" | snakecase }}`, "foo_bar_baz"},
+		{`{{ "FooBar_baz" | kebabcase }}`, "foo-bar-baz"},
+		{`{{ "foo_bar-baz" | camelcase }}`, "fooBarBaz"},
+	} {
+		result, err := executeFuncs(c[0], values)
+		assert.NoError(t, err, c[0])
+		assert.Equal(t, c[1], result, c[0])
+	}
+}
+
+func TestFuncsEnvSecretsKey(t *testing.T) {
+	os.Setenv(SecretsKeyEnv, "foo")
+	defer os.Unsetenv(SecretsKeyEnv)
+
+	_, err := executeFuncs(`{{ env "COMBUSTION_SECRETS_KEY" }}`, nil)
+	assert.Error(t, err)
+}
+
+func TestFuncsRequired(t *testing.T) {
+	_, err := executeFuncs(`{{ .empty | required "empty is required" }}`, Values{"empty": ""})
+	assert.Error(t, err)
+}
+
+func TestConfigInterpolateFuncs(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"    - name: {{ .name | upper }}\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"name": "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "FOO", string(c.Systemd.Units[0].Name))
+}
+
+func executeFuncs(text string, v Values) (string, error) {
+	tmpl, err := template.New("test").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = tmpl.Execute(buf, v)
+	return buf.String(), err
+}
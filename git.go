@@ -0,0 +1,114 @@
+package combustion
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const gitScheme = "git+file://"
+
+// gitPath is a file from a local git repository at a given revision, written
+// as git+file://<repository>//<path>?ref=<revision>, when the ref is missing
+// HEAD is used
+type gitPath struct {
+	Repository string
+	Path       string
+	Ref        string
+}
+
+// isGit returns true if the path is a git+file url
+func isGit(path string) bool {
+	return strings.HasPrefix(path, gitScheme)
+}
+
+func parseGitPath(s string) (*gitPath, error) {
+	if !isGit(s) {
+		return nil, fmt.Errorf("invalid git path %q, expected %s prefix", s, gitScheme)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(u.Path, "//", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf(
+			"invalid git path %q, expected %s<repository>//<path>", s, gitScheme,
+		)
+	}
+
+	p := &gitPath{Repository: parts[0], Path: parts[1], Ref: u.Query().Get("ref")}
+	if p.Ref == "" {
+		p.Ref = "HEAD"
+	}
+
+	return p, nil
+}
+
+// resolveGitPath returns the given git path with the ref resolved to a commit
+// hash, so every file read from it belongs to the same revision
+func resolveGitPath(s string) (string, error) {
+	p, err := parseGitPath(s)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := git(p.Repository, "rev-parse", "--verify", p.Ref+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+
+	p.Ref = strings.TrimSpace(string(out))
+	return p.String(), nil
+}
+
+// Join returns the gitPath of file relative to the p, as a directory, the
+// result can't be outside of the repository
+func (p *gitPath) Join(file string) (*gitPath, error) {
+	joined := path.Join(p.Path, file)
+	if path.IsAbs(file) {
+		joined = path.Clean(file[1:])
+	}
+
+	if joined == ".." || strings.HasPrefix(joined, "../") {
+		return nil, fmt.Errorf("%q is outside of the repository %q", file, p.Repository)
+	}
+
+	return &gitPath{Repository: p.Repository, Path: joined, Ref: p.Ref}, nil
+}
+
+// Split splits p into a directory and a filename
+func (p *gitPath) Split() (dir, file string) {
+	dir, file = path.Split(p.Path)
+	return (&gitPath{Repository: p.Repository, Path: dir, Ref: p.Ref}).String(), file
+}
+
+// Read returns the content of the file, without a checkout of the repository
+func (p *gitPath) Read() ([]byte, error) {
+	return git(p.Repository, "show", p.Ref+":"+p.Path)
+}
+
+func (p *gitPath) String() string {
+	return gitScheme + p.Repository + "//" + p.Path + "?ref=" + url.QueryEscape(p.Ref)
+}
+
+func git(repository string, args ...string) ([]byte, error) {
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("git", append([]string{"-C", repository}, args...)...)
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error running git %s at %q: %s",
+			strings.Join(args, " "), repository, strings.TrimSpace(stderr.String()),
+		)
+	}
+
+	return out, nil
+}
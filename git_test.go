@@ -0,0 +1,104 @@
+package combustion
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigResolveGit(t *testing.T) {
+	repository := newGitRepository(t)
+	defer os.RemoveAll(repository)
+
+	commitGitFiles(t, repository, "v1", map[string]string{
+		"includes/foo.yaml":     "import:\n  - bar.yaml\nsystemd:\n  units:\n   - name: foo",
+		"includes/bar.yaml":     "import:\n  - ../qux.yaml\nsystemd:\n  units:\n   - name: bar",
+		"qux.yaml":              "systemd:\n  units:\n   - name: qux",
+		"includes/invalid.yaml": "import:\n  - ../../qux.yaml",
+	})
+
+	commitGitFiles(t, repository, "v2", map[string]string{
+		"includes/bar.yaml": "systemd:\n  units:\n   - name: bar v2",
+	})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - git+file://" + repository + "//includes/foo.yaml?ref=v1\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo", "bar", "qux"}, unitNames(c))
+
+	input = []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - git+file://" + repository + "//includes/invalid.yaml\n" +
+		"",
+	)
+
+	_, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.Error(t, err)
+}
+
+func TestParseGitPath(t *testing.T) {
+	p, err := parseGitPath("git+file:///foo/bar//qux/baz.yaml?ref=v1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, &gitPath{Repository: "/foo/bar", Path: "qux/baz.yaml", Ref: "v1.2"}, p)
+
+	p, err = parseGitPath("git+file:///foo/bar//baz.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "HEAD", p.Ref)
+
+	_, err = parseGitPath("git+file:///foo/bar/baz.yaml")
+	assert.Error(t, err)
+}
+
+func newGitRepository(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir, err := ioutil.TempDir("", "combustion-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, dir, "init", "-q")
+	return dir
+}
+
+func commitGitFiles(t *testing.T, repository, tag string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(repository, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runGit(t, repository, "add", "-A")
+	runGit(t, repository,
+		"-c", "user.name=combustion", "-c", "user.email=combustion@localhost",
+		"commit", "-q", "-m", tag,
+	)
+
+	runGit(t, repository, "tag", tag)
+}
+
+func runGit(t *testing.T, repository string, args ...string) {
+	if _, err := git(repository, args...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+package combustion
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// glob returns the sorted names of the files in the FileSystem matching the
+// pattern. The pattern syntax is the same used by filepath.Match, with the
+// addition of **, matching zero or more directories, or any file when is the
+// last element of the pattern.
+func glob(pattern string) ([]string, error) {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+
+	var base string
+	if filepath.IsAbs(pattern) {
+		base, parts = string(filepath.Separator), parts[1:]
+	}
+
+	matches := make(map[string]bool)
+	if err := doGlob(base, parts, matches); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for file := range matches {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func doGlob(base string, parts []string, matches map[string]bool) error {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	part, last := parts[0], len(parts) == 1
+	if part == "**" {
+		if err := doGlob(base, parts[1:], matches); err != nil {
+			return err
+		}
+	} else if !hasMeta(part) {
+		return globEntry(filepath.Join(base, part), parts, matches)
+	}
+
+	dir := base
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := FileSystem.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := filepath.Join(base, e.Name())
+		if part == "**" {
+			if last && !e.IsDir() {
+				matches[name] = true
+			}
+
+			if e.IsDir() {
+				if err := doGlob(name, parts, matches); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		ok, err := filepath.Match(part, e.Name())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if last && !e.IsDir() {
+			matches[name] = true
+		}
+
+		if !last && e.IsDir() {
+			if err := doGlob(name, parts[1:], matches); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func globEntry(name string, parts []string, matches map[string]bool) error {
+	if len(parts) > 1 {
+		return doGlob(name, parts[1:], matches)
+	}
+
+	fi, err := FileSystem.Stat(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		matches[name] = true
+	}
+
+	return nil
+}
+
+// hasMeta returns true if path contains any of the special characters
+// recognized by glob
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,52 @@
+package combustion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlob(t *testing.T) {
+	WriteFixtures([][]string{
+		{"fixtures/glob/units/b.yaml", ""},
+		{"fixtures/glob/units/a.yaml", ""},
+		{"fixtures/glob/units/c.txt", ""},
+		{"fixtures/glob/roles/base.yaml", ""},
+		{"fixtures/glob/roles/worker/base.yaml", ""},
+		{"fixtures/glob/roles/worker/gpu/base.yaml", ""},
+		{"fixtures/glob/roles/master/other.yaml", ""},
+	})
+
+	for _, c := range []struct {
+		pattern string
+		files   []string
+	}{{
+		"fixtures/glob/units/*.yaml",
+		[]string{"fixtures/glob/units/a.yaml", "fixtures/glob/units/b.yaml"},
+	}, {
+		"fixtures/glob/roles/**/base.yaml",
+		[]string{
+			"fixtures/glob/roles/base.yaml",
+			"fixtures/glob/roles/worker/base.yaml",
+			"fixtures/glob/roles/worker/gpu/base.yaml",
+		},
+	}, {
+		"fixtures/glob/*/[a-b].yaml",
+		[]string{"fixtures/glob/units/a.yaml", "fixtures/glob/units/b.yaml"},
+	}, {
+		"fixtures/glob/roles/**",
+		[]string{
+			"fixtures/glob/roles/base.yaml",
+			"fixtures/glob/roles/master/other.yaml",
+			"fixtures/glob/roles/worker/base.yaml",
+			"fixtures/glob/roles/worker/gpu/base.yaml",
+		},
+	}, {
+		"fixtures/glob/missing/*.yaml",
+		nil,
+	}} {
+		files, err := glob(c.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, c.files, files, c.pattern)
+	}
+}
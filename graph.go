@@ -0,0 +1,162 @@
+package combustion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// Graph is the import graph of one or more configs, the nodes are the files
+// and the edges the imports between them
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+
+	nodes map[string]*Node
+	edges map[string]bool
+}
+
+// Node is a file of the import graph
+type Node struct {
+	File string `json:"file"`
+	// Output of the file, only present on the root files
+	Output string `json:"output,omitempty"`
+}
+
+// Edge is the import of the file To by the file From with the given values
+type Edge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Values Values `json:"values,omitempty"`
+	// Conditional is true if the import has a when condition
+	Conditional bool `json:"conditional,omitempty"`
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		Nodes: []*Node{},
+		Edges: []*Edge{},
+		nodes: make(map[string]*Node),
+		edges: make(map[string]bool),
+	}
+}
+
+// NewGraph returns the merge of the given graphs
+func NewGraph(graphs ...*Graph) *Graph {
+	g := newGraph()
+	for _, o := range graphs {
+		for _, n := range o.Nodes {
+			g.addNode(n.File, n.Output)
+		}
+
+		for _, e := range o.Edges {
+			g.addEdge(e.From, e.To, e.Values, e.Conditional)
+		}
+	}
+
+	return g
+}
+
+func (g *Graph) addNode(file, output string) {
+	if n, ok := g.nodes[file]; ok {
+		if n.Output == "" {
+			n.Output = output
+		}
+
+		return
+	}
+
+	n := &Node{File: file, Output: output}
+	g.nodes[file] = n
+	g.Nodes = append(g.Nodes, n)
+}
+
+func (g *Graph) addEdge(from, to string, values Values, conditional bool) {
+	key := importKey(from+"\x00"+to, values)
+	if g.edges[key] {
+		return
+	}
+
+	g.edges[key] = true
+	g.Edges = append(g.Edges, &Edge{
+		From: from, To: to, Values: values, Conditional: conditional,
+	})
+}
+
+// WriteJSON writes the graph to w as JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	content, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", content)
+	return err
+}
+
+// WriteDot writes the graph to w in the DOT language, the edges are labeled
+// with the values and the conditional ones are dashed
+func (g *Graph) WriteDot(w io.Writer) error {
+	lines := []string{"digraph imports {"}
+	for _, n := range g.Nodes {
+		if n.Output == "" {
+			lines = append(lines, fmt.Sprintf("  %s;", strconv.Quote(n.File)))
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"  %s [shape=box, label=%s];",
+			strconv.Quote(n.File), strconv.Quote(n.File+"\n-> "+n.Output),
+		))
+	}
+
+	for _, e := range g.Edges {
+		var attrs []string
+		if len(e.Values) != 0 {
+			attrs = append(attrs, "label="+strconv.Quote(e.Values.label()))
+		}
+
+		if e.Conditional {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		line := fmt.Sprintf("  %s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		if len(attrs) != 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+
+		lines = append(lines, line+";")
+	}
+
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// label returns the values as key=value lines, sorted by key
+func (v Values) label() string {
+	var keys []string
+	for k := range v {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		value := fmt.Sprint(v[k])
+		if !isScalar(v[k]) {
+			y, _ := yaml.Marshal(v[k])
+			value = strings.TrimSpace(string(y))
+		}
+
+		lines = append(lines, fmt.Sprintf("%s=%s", k, value))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,153 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigGraph(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/graph/foo.yaml",
+		"import:\n  - qux.yaml\n",
+	}, {
+		"fixtures/graph/bar.yaml",
+		"import:\n  - qux.yaml\n",
+	}, {
+		"fixtures/graph/qux.yaml",
+		"systemd:\n  units:\n   - name: qux",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"output: foo.json\n" +
+		"import:\n" +
+		"  - file: graph/foo.yaml\n" +
+		"    values:\n" +
+		"      action: reboot\n" +
+		"  - graph/bar.yaml\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	g := c.Graph()
+	assert.Equal(t, []*Node{
+		{File: "fixtures/inline.yaml", Output: "foo.json"},
+		{File: "fixtures/graph/foo.yaml"},
+		{File: "fixtures/graph/qux.yaml"},
+		{File: "fixtures/graph/bar.yaml"},
+	}, g.Nodes)
+
+	assert.Equal(t, []*Edge{
+		{From: "fixtures/inline.yaml", To: "fixtures/graph/foo.yaml", Values: Values{"action": "reboot"}},
+		{From: "fixtures/graph/foo.yaml", To: "fixtures/graph/qux.yaml"},
+		{From: "fixtures/inline.yaml", To: "fixtures/graph/bar.yaml"},
+		{From: "fixtures/graph/bar.yaml", To: "fixtures/graph/qux.yaml"},
+	}, g.Edges)
+}
+
+func TestConfigGraphImportsOnly(t *testing.T) {
+	WriteFixture("fixtures/graph/files.yaml", ""+
+		"systemd:\n  units:\n   - name: {{ .action }} <% .Missing %>\n"+
+		"storage:\n  files:\n   - path: /foo\n     filesystem: root\n"+
+		"     contents:\n       remote:\n         url: file:///missing.txt\n",
+	)
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - graph/files.yaml\n" +
+		"",
+	)
+
+	c, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/inline.yaml", nil,
+		AllowMissing(), ImportsOnly(),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*Edge{
+		{From: "fixtures/inline.yaml", To: "fixtures/graph/files.yaml"},
+	}, c.Graph().Edges)
+}
+
+func TestConfigGraphAllowMissingWhen(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/graph/worker.yaml",
+		"systemd:\n  units:\n   - name: worker {{ .action }}",
+	}, {
+		"fixtures/graph/master.yaml",
+		"systemd:\n  units:\n   - name: master",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: graph/worker.yaml\n" +
+		"    when: '{{ eq .role \"worker\" }}'\n" +
+		"  - file: graph/master.yaml\n" +
+		"    when: '{{ .master }}'\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil, AllowMissing())
+	assert.NoError(t, err)
+	assert.Equal(t, []*Edge{
+		{From: "fixtures/inline.yaml", To: "fixtures/graph/worker.yaml", Conditional: true},
+		{From: "fixtures/inline.yaml", To: "fixtures/graph/master.yaml", Conditional: true},
+	}, c.Graph().Edges)
+
+	var files []string
+	for _, v := range c.Vars() {
+		files = append(files, v.File)
+	}
+
+	assert.Equal(t, []string{
+		"fixtures/inline.yaml",
+		"fixtures/graph/worker.yaml",
+		"fixtures/graph/master.yaml",
+	}, files)
+}
+
+func TestGraphWriteDot(t *testing.T) {
+	g := newGraph()
+	g.addNode("foo.yaml", "foo.json")
+	g.addNode("bar.yaml", "")
+	g.addNode("qux.yaml", "")
+	g.addEdge("foo.yaml", "bar.yaml", Values{"b": "qux", "a": []interface{}{"foo"}}, false)
+	g.addEdge("foo.yaml", "bar.yaml", Values{"a": []interface{}{"foo"}, "b": "qux"}, false)
+	g.addEdge("foo.yaml", "qux.yaml", nil, true)
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, g.WriteDot(buf))
+	assert.Equal(t, ""+
+		"digraph imports {\n"+
+		"  \"foo.yaml\" [shape=box, label=\"foo.yaml\\n-> foo.json\"];\n"+
+		"  \"bar.yaml\";\n"+
+		"  \"qux.yaml\";\n"+
+		"  \"foo.yaml\" -> \"bar.yaml\" [label=\"a=- foo\\nb=qux\"];\n"+
+		"  \"foo.yaml\" -> \"qux.yaml\" [style=dashed];\n"+
+		"}\n", buf.String(),
+	)
+}
+
+func TestGraphWriteJSON(t *testing.T) {
+	g := NewGraph(newGraph(), newGraph())
+	g.addNode("foo.yaml", "")
+
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, g.WriteJSON(buf))
+	assert.Equal(t, ""+
+		"{\n"+
+		"  \"nodes\": [\n"+
+		"    {\n"+
+		"      \"file\": \"foo.yaml\"\n"+
+		"    }\n"+
+		"  ],\n"+
+		"  \"edges\": []\n"+
+		"}\n", buf.String(),
+	)
+}
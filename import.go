@@ -0,0 +1,343 @@
+package combustion
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// Import describes a file to be imported and the values used to interpolate it
+type Import struct {
+	File   string `yaml:"file"`
+	Values Values `yaml:"values,omitempty"`
+	// Multiple allows to import the file even if it was already imported
+	// with the same values by any other file
+	Multiple bool `yaml:"multiple,omitempty"`
+	// Hash of the file content in the form sha512-<hex sum>, required by
+	// remote imports
+	Hash string `yaml:"hash,omitempty"`
+	// When is a condition, the file is only imported if it is true. Being part
+	// of the importing file, it is rendered with the rest of the file, so it
+	// can be any template action resulting in true or false.
+	When string `yaml:"when,omitempty"`
+}
+
+// Imports is an ordered list of imports, the imported files are merged in the
+// same order they are listed
+type Imports []*Import
+
+// newImports decodes the import key of the given YAML content into Imports.
+// The key can be a sequence, where every item is a filename or an Import, or a
+// map of filenames to values, in this case the imports are sorted by filename,
+// so the merge order doesn't depend on the map iteration order.
+func newImports(y []byte) (Imports, error) {
+	var raw struct {
+		Imports interface{} `yaml:"import,omitempty"`
+	}
+
+	if err := yaml.Unmarshal(y, &raw); err != nil {
+		return nil, err
+	}
+
+	switch v := raw.Imports.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return newImportsFromSequence(y, v)
+	case map[interface{}]interface{}:
+		return newImportsFromMap(y, v)
+	default:
+		return nil, fmt.Errorf("invalid import, expected a sequence or a map")
+	}
+}
+
+func newImportsFromSequence(y []byte, seq []interface{}) (Imports, error) {
+	// the maps of the sequence decoded as strings, to preserve the literals
+	var literals struct {
+		Imports []struct {
+			Values map[string]string `yaml:"values"`
+		} `yaml:"import"`
+	}
+
+	if err := yaml.Unmarshal(y, &literals); err != nil {
+		return nil, err
+	}
+
+	var imports Imports
+	var maps int
+	for i, item := range seq {
+		imp := &Import{}
+		switch v := item.(type) {
+		case string:
+			imp.File = v
+		case map[interface{}]interface{}:
+			if err := remarshal(v, imp); err != nil {
+				return nil, err
+			}
+
+			var literal map[string]string
+			if maps < len(literals.Imports) {
+				literal = literals.Imports[maps].Values
+			}
+
+			var err error
+			imp.Values, err = newValues(v["values"], literal)
+			if err != nil {
+				return nil, fmt.Errorf("invalid import[%d], %s", i, err)
+			}
+
+			maps++
+		default:
+			return nil, fmt.Errorf("invalid import[%d], expected a filename or a map", i)
+		}
+
+		if imp.File == "" {
+			return nil, fmt.Errorf("invalid import[%d], missing file", i)
+		}
+
+		imports = append(imports, imp)
+	}
+
+	return imports, nil
+}
+
+func newImportsFromMap(y []byte, m map[interface{}]interface{}) (Imports, error) {
+	// the values decoded as strings, to preserve the literals
+	var literals struct {
+		Imports map[string]map[string]string `yaml:"import"`
+	}
+
+	if err := yaml.Unmarshal(y, &literals); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for k := range m {
+		file, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid import %v, expected a filename", k)
+		}
+
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	var imports Imports
+	for _, file := range files {
+		values, err := newValues(m[file], literals.Imports[file])
+		if err != nil {
+			return nil, fmt.Errorf("invalid import %q, %s", file, err)
+		}
+
+		imports = append(imports, &Import{File: file, Values: values})
+	}
+
+	return imports, nil
+}
+
+// enabled returns if the import should be done, the When condition is
+// already rendered with the importing file, so it is only parsed
+func (i *Import) enabled() (bool, error) {
+	when := strings.TrimSpace(i.When)
+	if when == "" {
+		return true, nil
+	}
+
+	ok, err := strconv.ParseBool(when)
+	if err != nil {
+		return false, fmt.Errorf(
+			"invalid condition importing %q, expected true or false, got %q", i.File, i.When,
+		)
+	}
+
+	return ok, nil
+}
+
+// expand returns the full path of the files to be imported, relative to dir or
+// to the search paths for library imports, local imports containing a glob
+// pattern are expanded to the matching files, except the importing file from
+func (i *Import) expand(dir, from string, o *options) ([]string, error) {
+	var fullpath string
+	var err error
+	if isLibrary(i.File) {
+		fullpath, err = o.lookup(i.File)
+	} else {
+		fullpath, err = joinPath(dir, i.File)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isRemote(fullpath) || isGit(fullpath) || !hasMeta(fullpath) {
+		return []string{fullpath}, nil
+	}
+
+	matches, err := glob(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range matches {
+		if file != from {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// open returns the content of the import located at fullpath, remote imports
+// are retrieved through the DefaultCache and git imports are read from the
+// repository
+func (i *Import) open(fullpath string) (io.Reader, error) {
+	if isGit(fullpath) {
+		p, err := parseGitPath(fullpath)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := p.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(content), nil
+	}
+
+	if !isRemote(fullpath) {
+		return FileSystem.Open(fullpath)
+	}
+
+	if i.Hash == "" {
+		return nil, fmt.Errorf("missing hash for remote import %q", fullpath)
+	}
+
+	content, err := DefaultCache.Get(fullpath, i.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// importKey returns the key identifying the file with the given values
+func importKey(fullpath string, v Values) string {
+	values, _ := yaml.Marshal(v)
+	return fullpath + "\x00" + string(values)
+}
+
+// importedFile is a file already imported, with all the values it received
+// and the ones given directly in the import
+type importedFile struct {
+	config   *Config
+	values   Values
+	explicit Values
+}
+
+// matches returns true if importing the file again with the given values
+// results in the same file, so it is imported only once unless Multiple is
+// set. The explicit values should be the same, but from the inherited ones
+// only the values used by the file, or by its imports, or declared as params
+// are compared, the rest can't change the result. If the file uses all the
+// values, like with {{ toJson . }}, all of them are compared.
+func (f *importedFile) matches(values, explicit Values) bool {
+	if importKey("", f.explicit) != importKey("", explicit) {
+		return false
+	}
+
+	if f.config.uses[allValues] {
+		return importKey("", f.values) == importKey("", values)
+	}
+
+	names := make(map[string]bool, len(f.config.uses)+len(f.config.Params))
+	for name := range f.config.uses {
+		names[name] = true
+	}
+
+	for _, p := range f.config.Params {
+		names[p.Name] = true
+	}
+
+	for name := range names {
+		old, oldOK := f.values[name]
+		value, ok := values[name]
+		if ok != oldOK || importKey("", Values{name: old}) != importKey("", Values{name: value}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// joinPath returns the path of file relative to dir, dir can be a local
+// directory, a remote url or a directory from a git repository
+func joinPath(dir, file string) (string, error) {
+	switch {
+	case isRemote(file):
+		return file, nil
+	case isGit(file):
+		return resolveGitPath(file)
+	case isRemote(dir):
+		base, err := url.Parse(dir)
+		if err != nil {
+			return "", err
+		}
+
+		ref, err := url.Parse(file)
+		if err != nil {
+			return "", err
+		}
+
+		return base.ResolveReference(ref).String(), nil
+	case isGit(dir):
+		p, err := parseGitPath(dir)
+		if err != nil {
+			return "", err
+		}
+
+		p, err = p.Join(file)
+		if err != nil {
+			return "", err
+		}
+
+		return p.String(), nil
+	default:
+		return filepath.Join(dir, file), nil
+	}
+}
+
+// splitPath splits fullpath into a directory and a filename, the directory
+// can be used with joinPath
+func splitPath(fullpath string) (dir, file string) {
+	if isGit(fullpath) {
+		if p, err := parseGitPath(fullpath); err == nil {
+			return p.Split()
+		}
+	}
+
+	return filepath.Split(fullpath)
+}
+
+// remarshal converts a generic YAML value into the given out value
+func remarshal(in, out interface{}) error {
+	if in == nil {
+		return nil
+	}
+
+	y, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(y, out)
+}
@@ -0,0 +1,100 @@
+package combustion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewImportsSequence(t *testing.T) {
+	imports, err := newImports([]byte("" +
+		"import:\n" +
+		"- foo.yaml\n" +
+		"- file: bar.yaml\n" +
+		"  values:\n" +
+		"    qux: baz\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Len(t, imports, 2)
+	assert.Equal(t, &Import{File: "foo.yaml"}, imports[0])
+	assert.Equal(t, &Import{File: "bar.yaml", Values: Values{"qux": "baz"}}, imports[1])
+}
+
+func TestNewImportsSequenceMissingFile(t *testing.T) {
+	_, err := newImports([]byte("import:\n- values:\n    qux: baz\n"))
+	assert.Error(t, err)
+}
+
+func TestNewImportsMap(t *testing.T) {
+	imports, err := newImports([]byte("" +
+		"import:\n" +
+		"  qux.yaml:\n" +
+		"  foo.yaml:\n" +
+		"    bar: baz\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Len(t, imports, 2)
+	assert.Equal(t, &Import{File: "foo.yaml", Values: Values{"bar": "baz"}}, imports[0])
+	assert.Equal(t, &Import{File: "qux.yaml"}, imports[1])
+}
+
+func TestNewImportsInvalid(t *testing.T) {
+	_, err := newImports([]byte("import: foo.yaml"))
+	assert.Error(t, err)
+}
+
+func TestNewImportsTypedValues(t *testing.T) {
+	imports, err := newImports([]byte("" +
+		"import:\n" +
+		"- foo.yaml\n" +
+		"- file: bar.yaml\n" +
+		"  values:\n" +
+		"    keys: [foo, bar]\n" +
+		"    mounts: {foo: /bar}\n" +
+		"    count: 3\n" +
+		"    enabled: true\n" +
+		"    version: 1.10\n" +
+		"    mode: 0644\n" +
+		"    empty:\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Values{
+		"keys":    []interface{}{"foo", "bar"},
+		"mounts":  map[string]interface{}{"foo": "/bar"},
+		"count":   3,
+		"enabled": true,
+		"version": "1.10",
+		"mode":    "0644",
+		"empty":   "",
+	}, imports[1].Values)
+}
+
+func TestImportKey(t *testing.T) {
+	foo := Values{"a": "b", "c": "d"}
+	bar := Values{"c": "d", "a": "b"}
+	qux := Values{"a": "b"}
+
+	assert.Equal(t, importKey("/foo.yaml", foo), importKey("/foo.yaml", bar))
+	assert.NotEqual(t, importKey("/foo.yaml", foo), importKey("/foo.yaml", qux))
+	assert.NotEqual(t, importKey("/foo.yaml", foo), importKey("/bar/foo.yaml", foo))
+}
+
+func TestImportEnabled(t *testing.T) {
+	for _, c := range []struct {
+		when string
+		ok   bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", false},
+		{" true\n", true},
+	} {
+		imp := &Import{File: "foo.yaml", When: c.when}
+		ok, err := imp.enabled()
+		assert.NoError(t, err)
+		assert.Equal(t, c.ok, ok, c.when)
+	}
+}
@@ -0,0 +1,127 @@
+package combustion
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// lateLeft and lateRight are the delimiters of the late actions, they are not
+// executed when the file is interpolated, but once all the imports are merged,
+// with the merged config as data
+const lateLeft, lateRight = "<%", "%>"
+
+// lateLeftMarker and lateRightMarker replace the delimiters of the late actions
+// written in the source of the files, before they are interpolated, so only
+// those are executed and never a <% coming from a value or from the content of
+// a local or remote file. The markers contain a random token, so they can't be
+// forged by a value.
+var lateLeftMarker, lateRightMarker = newLateMarkers()
+
+func newLateMarkers() (string, string) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+
+	t := hex.EncodeToString(token)
+	return lateLeft + t, t + lateRight
+}
+
+// lateRegexp matches the late actions and the escaped late delimiter
+var lateRegexp = regexp.MustCompile(`\\` + lateLeft + `|` + lateLeft + `(.+?)` + lateRight)
+
+// late replaces, in the template source, the delimiters of every late action
+// with the markers. A late delimiter preceded by a backslash, like \<%, is not
+// translated and is written without the backslash.
+func (s *syntax) late(content []byte) ([]byte, []edit) {
+	if !s.Late {
+		return content, nil
+	}
+
+	return replaceAll(lateRegexp, content, func(match []byte) []byte {
+		if match[0] == '\\' {
+			return match[1:]
+		}
+
+		inner := match[len(lateLeft) : len(match)-len(lateRight)]
+
+		r := make([]byte, 0, len(lateLeftMarker)+len(inner)+len(lateRightMarker))
+		r = append(r, lateLeftMarker...)
+		r = append(r, inner...)
+		return append(r, lateRightMarker...)
+	})
+}
+
+// interpolateLate executes the late actions found in any string of the merged
+// config. Every string is executed against the config before any of them is
+// replaced, so the result doesn't depend on the order of the fields.
+func (c *Config) interpolateLate() error {
+	var fields []reflect.Value
+	lateFields(reflect.ValueOf(&c.Config).Elem(), &fields)
+
+	results := make([]string, len(fields))
+	for i, f := range fields {
+		t, err := template.New(c.path()).
+			Delims(lateLeftMarker, lateRightMarker).
+			Funcs(c.options.funcs()).
+			Option("missingkey=error").
+			Parse(f.String())
+		if err != nil {
+			return c.lateError(err)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := t.Execute(buf, c.Config); err != nil {
+			return c.lateError(err)
+		}
+
+		results[i] = buf.String()
+	}
+
+	for i, f := range fields {
+		f.SetString(results[i])
+	}
+
+	return nil
+}
+
+// lateError returns an ErrConfig without position, since the position of the
+// template error is relative to the field and not to the file, and with the
+// markers replaced by the late delimiters
+func (c *Config) lateError(err error) error {
+	e := newErrConfig(c.path(), nil, err)
+	e.Line, e.Column = 0, 0
+	e.Message = strings.NewReplacer(
+		lateLeftMarker, lateLeft, lateRightMarker, lateRight,
+	).Replace(e.Message)
+
+	return e
+}
+
+// lateFields appends to fields the settable strings containing late actions,
+// the ones with the markers
+func lateFields(v reflect.Value, fields *[]reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			lateFields(v.Elem(), fields)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			lateFields(v.Field(i), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			lateFields(v.Index(i), fields)
+		}
+	case reflect.String:
+		if v.CanSet() && strings.Contains(v.String(), lateLeftMarker) {
+			*fields = append(*fields, v)
+		}
+	}
+}
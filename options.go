@@ -0,0 +1,264 @@
+package combustion
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Option configures how a Config and its imports are resolved
+type Option func(*options)
+
+type options struct {
+	searchPaths  []string
+	secrets      *SecretStore
+	allowMissing bool
+	root         string
+	hostFiles    bool
+	pinRemote    bool
+	inlineRemote bool
+	importsOnly  bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+const libraryPrefix = "lib:"
+
+// SearchPaths sets the local directories where the imports prefixed with
+// "lib:" are searched, in the given order
+func SearchPaths(paths ...string) Option {
+	return func(o *options) {
+		o.searchPaths = append(o.searchPaths, paths...)
+	}
+}
+
+// Secrets sets the store used by the secret function of the templates
+func Secrets(store *SecretStore) Option {
+	return func(o *options) {
+		o.secrets = store
+	}
+}
+
+// Root sets the directory the file urls can't point outside of, a file url
+// escaping it, like file:///../../etc/passwd, is an error
+func Root(dir string) Option {
+	return func(o *options) {
+		o.root = filepath.Clean(dir)
+	}
+}
+
+// AllowHostFiles allows the file+host urls, referencing absolute paths of the
+// host, like file+host:///etc/hostname
+func AllowHostFiles() Option {
+	return func(o *options) {
+		o.hostFiles = true
+	}
+}
+
+// PinRemoteFiles fetches, at build time, the remote files of the storage
+// without a verification hash, using the DefaultCache, and sets their hash
+func PinRemoteFiles() Option {
+	return func(o *options) {
+		o.pinRemote = true
+	}
+}
+
+// InlineRemoteFiles fetches, at build time, the remote files of the storage,
+// using the DefaultCache, and includes their content, as the local files, so
+// the output can be used without network access
+func InlineRemoteFiles() Option {
+	return func(o *options) {
+		o.inlineRemote = true
+	}
+}
+
+const (
+	fileScheme         = "file"
+	hostFileScheme     = "file+host"
+	templateFileScheme = "file+tmpl"
+)
+
+// isLocalScheme returns true if the scheme references a local file
+func isLocalScheme(scheme string) bool {
+	return scheme == fileScheme || scheme == hostFileScheme || scheme == templateFileScheme
+}
+
+// hasLocalScheme returns true if s is an url referencing a local file
+func hasLocalScheme(s string) bool {
+	for _, scheme := range []string{fileScheme, hostFileScheme, templateFileScheme} {
+		if strings.HasPrefix(s, scheme+"://") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localPath returns the path of the file referenced by a file or file+tmpl
+// url, relative to the given dir and inside of the root, or by a file+host
+// url, an absolute path
+func (o *options) localPath(dir string, u *url.URL) (string, error) {
+	if u.Scheme == hostFileScheme {
+		if o == nil || !o.hostFiles {
+			return "", fmt.Errorf("%s urls are not allowed, %q", hostFileScheme, u)
+		}
+
+		if u.Host != "" || !path.IsAbs(u.Path) {
+			return "", fmt.Errorf(
+				"invalid url %q, expected %s:///<absolute path>", u, hostFileScheme,
+			)
+		}
+
+		return filepath.FromSlash(u.Path), nil
+	}
+
+	name := strings.TrimPrefix(u.Host+u.Path, "/")
+	filename := filepath.Join(dir, filepath.FromSlash(name))
+	if !o.inRoot(filename) {
+		return "", fmt.Errorf("%q is outside of the root %q", u, o.root)
+	}
+
+	return filename, nil
+}
+
+// inRoot returns true if the filename is inside of the root, or if there is
+// no root. The symlinks of both are resolved first, so a symlink inside of the
+// root pointing outside of it is outside of the root.
+func (o *options) inRoot(filename string) bool {
+	if o == nil || o.root == "" {
+		return true
+	}
+
+	rel, err := filepath.Rel(evalSymlinks(o.root), evalSymlinks(filename))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalSymlinks returns the filename with the symlinks resolved, when the file
+// doesn't exist the symlinks of its closest existing parent are resolved
+func evalSymlinks(filename string) string {
+	filename = filepath.Clean(filename)
+	resolved, err := filepath.EvalSymlinks(filename)
+	if err == nil {
+		return resolved
+	}
+
+	dir := filepath.Dir(filename)
+	if dir == filename {
+		return filename
+	}
+
+	return filepath.Join(evalSymlinks(dir), filepath.Base(filename))
+}
+
+// AllowMissing allows to resolve the files with missing values, the missing
+// values are rendered as "<no value>" and the required params are not
+// enforced. Every conditional import is followed, since its condition may
+// depend on the missing values. The result is not valid to be saved, but
+// allows to inspect the files, like with Vars.
+func AllowMissing() Option {
+	return func(o *options) {
+		o.allowMissing = true
+	}
+}
+
+// enabled returns true if the import should be resolved, with missing values
+// allowed the conditions are not evaluated and the import is always followed
+func (o *options) enabled(imp *Import) (bool, error) {
+	if o != nil && o.allowMissing {
+		return true, nil
+	}
+
+	return imp.enabled()
+}
+
+// ImportsOnly resolves only the imports of the files, the local files, the
+// trees and the remote files are not loaded and the late actions are not
+// executed, so nothing is read but the imported files, like to build the
+// import graph
+func ImportsOnly() Option {
+	return func(o *options) {
+		o.importsOnly = true
+	}
+}
+
+// missingKey returns the missingkey option of the templates
+func (o *options) missingKey() string {
+	if o != nil && o.allowMissing {
+		return "missingkey=default"
+	}
+
+	return "missingkey=error"
+}
+
+// funcs returns the functions available in the templates, with the secret
+// function reading from the configured store
+func (o *options) funcs() template.FuncMap {
+	if o == nil || o.secrets == nil {
+		return funcs
+	}
+
+	m := make(template.FuncMap, len(funcs))
+	for name, fn := range funcs {
+		m[name] = fn
+	}
+
+	m["secret"] = o.secrets.Get
+	return m
+}
+
+// isLibrary returns true if the path should be searched in the search paths
+func isLibrary(path string) bool {
+	return strings.HasPrefix(path, libraryPrefix)
+}
+
+// lookup returns the full path of the given library file, from the first
+// search path containing it, in case of a glob pattern the first search path
+// with any match is used. Only local search paths are supported.
+func (o *options) lookup(file string) (string, error) {
+	name := strings.TrimPrefix(file, libraryPrefix)
+	for _, dir := range o.searchPaths {
+		if strings.Contains(dir, "://") {
+			return "", fmt.Errorf("invalid search path %q, only local directories are supported", dir)
+		}
+
+		fullpath, err := joinPath(dir, name)
+		if err != nil {
+			return "", err
+		}
+
+		if hasMeta(fullpath) {
+			files, err := glob(fullpath)
+			if err != nil {
+				return "", err
+			}
+
+			if len(files) != 0 {
+				return fullpath, nil
+			}
+
+			continue
+		}
+
+		_, err = FileSystem.Stat(fullpath)
+		if err == nil {
+			return fullpath, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("%q not found in search paths %q", file, o.searchPaths)
+}
@@ -0,0 +1,224 @@
+package combustion
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// Param describes a value accepted by a file
+type Param struct {
+	Name string `yaml:"name"`
+	// Type of the value: string, int, float, bool, list or map, if empty any
+	// value is accepted
+	Type        string      `yaml:"type,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Required    bool        `yaml:"required,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+}
+
+// Params list of params declared by a file
+type Params []*Param
+
+// paramsBlock matches the top level params key, including the comments at any
+// column, the params are read before the content is interpolated, so it can't
+// contain any template action
+var paramsBlock = regexp.MustCompile(`(?m)^params:.*(\n([ \t].*|- .*|-|#.*|))*`)
+
+// newParams decodes the params key of the given YAML content, before being
+// interpolated
+func newParams(y []byte) (Params, error) {
+	block := paramsBlock.Find(y)
+	if block == nil {
+		return nil, nil
+	}
+
+	return decodeParams(block)
+}
+
+// check returns an error if the params key of the interpolated content
+// differs from the params read before the interpolation
+func (p Params) check(y []byte) error {
+	interpolated, err := decodeParams(y)
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(p, interpolated) {
+		return fmt.Errorf("invalid params, they can't contain template actions")
+	}
+
+	return nil
+}
+
+// decodeParams decodes the params key of the given YAML content
+func decodeParams(block []byte) (Params, error) {
+	var raw struct {
+		Params Params `yaml:"params"`
+	}
+
+	if err := yaml.Unmarshal(block, &raw); err != nil {
+		return nil, fmt.Errorf("invalid params: %s", err)
+	}
+
+	// the defaults decoded as strings, to preserve the literals
+	var literals struct {
+		Params []struct {
+			Default string `yaml:"default"`
+		} `yaml:"params"`
+	}
+
+	if err := yaml.Unmarshal(block, &literals); err != nil {
+		return nil, fmt.Errorf("invalid params: %s", err)
+	}
+
+	for i, p := range raw.Params {
+		if p.Name == "" {
+			return nil, fmt.Errorf("invalid params[%d], missing name", i)
+		}
+
+		if _, ok := paramTypes[p.Type]; !ok {
+			return nil, fmt.Errorf("invalid param %q, unknown type %q", p.Name, p.Type)
+		}
+
+		if p.Default == nil {
+			continue
+		}
+
+		var literal string
+		if i < len(literals.Params) {
+			literal = literals.Params[i].Default
+		}
+
+		p.Default = literalValue(p.Default, literal, true)
+	}
+
+	return raw.Params, nil
+}
+
+// validate checks the values against the params, returning the values with
+// the defaults of the missing ones and every value converted to the declared
+// type. Only the explicit values, the ones given directly to the file and not
+// inherited, are checked for not declared values. In case of error the values
+// are returned anyway, with the defaults and the valid conversions.
+func (p Params) validate(values, explicit Values) (Values, error) {
+	if p == nil {
+		return values, nil
+	}
+
+	r := make(Values, len(values))
+	for k, v := range values {
+		r[k] = v
+	}
+
+	var errs []string
+	declared := make(map[string]bool, len(p))
+	for _, param := range p {
+		declared[param.Name] = true
+
+		v, ok := r[param.Name]
+		if !ok {
+			if param.Default != nil {
+				r[param.Name] = param.Default
+			} else if param.Required {
+				errs = append(errs, fmt.Sprintf("missing required value %q", param.Name))
+			}
+
+			continue
+		}
+
+		v, err := paramTypes[param.Type](v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf(
+				"invalid value %q, expected %s: %s", param.Name, param.Type, err,
+			))
+
+			continue
+		}
+
+		r[param.Name] = v
+	}
+
+	var unknown []string
+	for k := range explicit {
+		if !declared[k] {
+			unknown = append(unknown, fmt.Sprintf("unknown value %q", k))
+		}
+	}
+
+	sort.Strings(unknown)
+	errs = append(errs, unknown...)
+	if len(errs) != 0 {
+		return r, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return r, nil
+}
+
+// paramTypes converts the values to the param types, strings are parsed so
+// values given as strings, like the CLI ones, are accepted
+var paramTypes = map[string]func(interface{}) (interface{}, error){
+	"": func(v interface{}) (interface{}, error) {
+		return v, nil
+	},
+	"string": func(v interface{}) (interface{}, error) {
+		if !isScalar(v) {
+			return nil, fmt.Errorf("got %T", v)
+		}
+
+		return fmt.Sprint(v), nil
+	},
+	"int": func(v interface{}) (interface{}, error) {
+		switch value := v.(type) {
+		case int, int64:
+			return value, nil
+		case string:
+			return strconv.Atoi(value)
+		default:
+			return nil, fmt.Errorf("got %T", v)
+		}
+	},
+	"float": func(v interface{}) (interface{}, error) {
+		switch value := v.(type) {
+		case float64:
+			return value, nil
+		case int:
+			return float64(value), nil
+		case int64:
+			return float64(value), nil
+		case string:
+			return strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("got %T", v)
+		}
+	},
+	"bool": func(v interface{}) (interface{}, error) {
+		switch value := v.(type) {
+		case bool:
+			return value, nil
+		case string:
+			return strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("got %T", v)
+		}
+	},
+	"list": func(v interface{}) (interface{}, error) {
+		if _, ok := v.([]interface{}); !ok {
+			return nil, fmt.Errorf("got %T", v)
+		}
+
+		return v, nil
+	},
+	"map": func(v interface{}) (interface{}, error) {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("got %T", v)
+		}
+
+		return v, nil
+	},
+}
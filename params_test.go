@@ -0,0 +1,159 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const paramsFixture = "" +
+	"params:\n" +
+	"  - name: action\n" +
+	"    type: string\n" +
+	"    required: true\n" +
+	"    description: action to run after the install\n" +
+	"  - name: count\n" +
+	"    type: int\n" +
+	"    default: 1\n" +
+	"  - name: version\n" +
+	"    default: 1.10\n" +
+	"\n" +
+	"systemd:\n" +
+	"  units:\n" +
+	"    - name: {{ .action }} {{ .count }} {{ .version }}\n"
+
+func TestNewParams(t *testing.T) {
+	params, err := newParams([]byte(paramsFixture))
+	assert.NoError(t, err)
+	assert.Equal(t, Params{{
+		Name:        "action",
+		Type:        "string",
+		Required:    true,
+		Description: "action to run after the install",
+	}, {
+		Name:    "count",
+		Type:    "int",
+		Default: 1,
+	}, {
+		Name:    "version",
+		Default: "1.10",
+	}}, params)
+}
+
+func TestNewParamsMissing(t *testing.T) {
+	params, err := newParams([]byte("systemd:\n  units:\n    - name: {{ .foo }}\n"))
+	assert.NoError(t, err)
+	assert.Nil(t, params)
+}
+
+func TestNewParamsComments(t *testing.T) {
+	params, err := newParams([]byte("" +
+		"params:\n" +
+		"  - name: foo\n" +
+		"# column 0 comment\n" +
+		"  - name: bar\n" +
+		"    # indented comment\n" +
+		"    type: int\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"    - name: {{ .foo }}\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Params{{Name: "foo"}, {Name: "bar", Type: "int"}}, params)
+}
+
+func TestNewParamsInvalidType(t *testing.T) {
+	_, err := newParams([]byte("params:\n  - name: foo\n    type: qux\n"))
+	assert.Error(t, err)
+}
+
+func TestConfigParamsTemplate(t *testing.T) {
+	input := []byte("" +
+		"params:\n" +
+		"  - name: foo\n" +
+		"    default: '{{ .bar }}'\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"    - name: {{ .foo }}\n",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"bar": "qux"})
+	assert.EqualError(t, err, ""+
+		"fixtures/inline.yaml: invalid params, they can't contain template actions",
+	)
+}
+
+func TestParamsValidate(t *testing.T) {
+	params, err := newParams([]byte(paramsFixture))
+	assert.NoError(t, err)
+
+	values, err := params.validate(Values{"action": "reboot", "count": "3"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Values{"action": "reboot", "count": 3, "version": "1.10"}, values)
+
+	_, err = params.validate(Values{"count": "foo"}, nil)
+	assert.EqualError(t, err, ""+
+		"missing required value \"action\", "+
+		"invalid value \"count\", expected int: "+
+		"strconv.Atoi: parsing \"foo\": invalid syntax",
+	)
+
+	_, err = params.validate(
+		Values{"action": "reboot", "actoin": "reboot", "inherited": "foo"},
+		Values{"action": "reboot", "actoin": "reboot"},
+	)
+
+	assert.EqualError(t, err, "unknown value \"actoin\"")
+}
+
+func TestConfigParams(t *testing.T) {
+	WriteFixture("fixtures/params/foo.yaml", paramsFixture)
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: params/foo.yaml\n" +
+		"    values:\n" +
+		"      action: reboot\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "reboot 1 1.10", string(c.Systemd.Units[0].Name))
+
+	input = []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - file: params/foo.yaml\n" +
+		"    values:\n" +
+		"      actoin: reboot\n" +
+		"",
+	)
+
+	_, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.EqualError(t, err, ""+
+		"fixtures/params/foo.yaml: "+
+		"missing required value \"action\", unknown value \"actoin\"",
+	)
+
+	// the values of the root file are inherited by its imports, not unknown
+	WriteFixtures([][]string{{
+		"fixtures/params/root.yaml",
+		"params:\n  - name: action\n" +
+			"import:\n  - channel.yaml\n" +
+			"systemd:\n  units:\n   - name: {{ .action }}",
+	}, {
+		"fixtures/params/channel.yaml",
+		"systemd:\n  units:\n   - name: {{ .coreos_channel }}",
+	}})
+
+	c, err = NewConfigFromFile("fixtures/params/root.yaml", Values{
+		"action": "reboot", "coreos_channel": "stable",
+	})
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"reboot", "stable"}, unitNames(c))
+}
@@ -0,0 +1,185 @@
+package combustion
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultCache cache used to store the remote imports
+var DefaultCache = &Cache{Dir: defaultCacheDir()}
+
+const hashPrefix = "sha512-"
+
+// httpClient is the client used to download the remote files, with a timeout
+// so a stalled server doesn't block the build forever
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Cache is a content-addressed cache of remote files, the files are stored in
+// the FileSystem using its hash as filename
+type Cache struct {
+	// Dir directory where the files are stored
+	Dir string
+	// Offline if true the files are never downloaded, requesting a file not
+	// present in the cache returns an error
+	Offline bool
+}
+
+// Get returns the content of the given url, the hash should be in the form
+// sha512-<hex sum>. The content is read from the cache if present, otherwise
+// is downloaded, validated against the hash and stored in the cache.
+func (c *Cache) Get(url, hash string) ([]byte, error) {
+	sum, err := parseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := c.filename(sum)
+	content, err := c.read(filename)
+	if err == nil && hashSum(content) == sum {
+		return content, nil
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if c.Offline {
+		return nil, fmt.Errorf("%q with hash %q not found in cache, offline mode", url, hash)
+	}
+
+	content, err = fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if got := hashSum(content); got != sum {
+		return nil, fmt.Errorf(
+			"hash mismatch downloading %q, expected %q, got %q",
+			url, hash, hashPrefix+got,
+		)
+	}
+
+	return content, c.write(filename, content)
+}
+
+// Fetch returns the content of the given url and its hash, in the form
+// sha512-<hex sum>. The content is always downloaded and stored in the cache,
+// except in offline mode, where the last content downloaded from the url is
+// returned.
+func (c *Cache) Fetch(url string) ([]byte, string, error) {
+	index := c.indexFilename(url)
+	if c.Offline {
+		hash, err := c.read(index)
+		if os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("%q not found in cache, offline mode", url)
+		}
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		content, err := c.Get(url, string(hash))
+		return content, string(hash), err
+	}
+
+	content, err := fetch(url)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sum := hashSum(content)
+	if err := c.write(c.filename(sum), content); err != nil {
+		return nil, "", err
+	}
+
+	hash := hashPrefix + sum
+	return content, hash, c.write(index, []byte(hash))
+}
+
+func (c *Cache) filename(sum string) string {
+	return filepath.Join(c.Dir, "sha512", sum)
+}
+
+// indexFilename returns the file storing the hash of the last content
+// downloaded from the url
+func (c *Cache) indexFilename(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, "urls", hex.EncodeToString(sum[:]))
+}
+
+func (c *Cache) read(filename string) ([]byte, error) {
+	f, err := FileSystem.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (c *Cache) write(filename string, content []byte) error {
+	f, err := FileSystem.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func fetch(url string) ([]byte, error) {
+	res, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %q: %s", url, res.Status)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+func parseHash(hash string) (string, error) {
+	if !strings.HasPrefix(hash, hashPrefix) {
+		return "", fmt.Errorf("invalid hash %q, expected %s<hex sum>", hash, hashPrefix)
+	}
+
+	sum := strings.ToLower(hash[len(hashPrefix):])
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha512.Size*2 {
+		return "", fmt.Errorf("invalid hash %q, expected %s<hex sum>", hash, hashPrefix)
+	}
+
+	return sum, nil
+}
+
+func hashSum(content []byte) string {
+	sum := sha512.Sum512(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// isRemote returns true if the path is a http or https url
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+func defaultCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "combustion")
+	}
+
+	return filepath.Join(os.Getenv("HOME"), ".cache", "combustion")
+}
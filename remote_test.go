@@ -0,0 +1,225 @@
+package combustion
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheGet(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, "foo")
+	}))
+	defer s.Close()
+
+	c := &Cache{Dir: "cache/get"}
+	content, err := c.Get(s.URL, hashPrefix+hashSum([]byte("foo")))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+
+	content, err = c.Get(s.URL, hashPrefix+hashSum([]byte("foo")))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+	assert.Equal(t, 1, requests)
+}
+
+func TestCacheGetHashMismatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "foo")
+	}))
+	defer s.Close()
+
+	c := &Cache{Dir: "cache/mismatch"}
+	_, err := c.Get(s.URL, hashPrefix+hashSum([]byte("bar")))
+	assert.Error(t, err)
+}
+
+func TestCacheGetOffline(t *testing.T) {
+	hash := hashPrefix + hashSum([]byte("foo"))
+
+	c := &Cache{Dir: "cache/offline", Offline: true}
+	_, err := c.Get("http://localhost/foo", hash)
+	assert.Error(t, err)
+
+	WriteFixture("cache/offline/sha512/"+hashSum([]byte("foo")), "foo")
+	content, err := c.Get("http://localhost/foo", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(content))
+}
+
+func TestCacheGetTimeout(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer s.Close()
+	defer close(done)
+
+	defer func(timeout time.Duration) { httpClient.Timeout = timeout }(httpClient.Timeout)
+	httpClient.Timeout = 10 * time.Millisecond
+
+	c := &Cache{Dir: "cache/timeout"}
+	_, err := c.Get(s.URL, hashPrefix+hashSum([]byte("foo")))
+	assert.Error(t, err)
+}
+
+func TestCacheGetInvalidHash(t *testing.T) {
+	c := &Cache{Dir: "cache/invalid"}
+	_, err := c.Get("http://localhost/foo", "md5-foo")
+	assert.Error(t, err)
+}
+
+func TestConfigResolveRemote(t *testing.T) {
+	defer func(c *Cache) { DefaultCache = c }(DefaultCache)
+	DefaultCache = &Cache{Dir: "cache/resolve"}
+
+	bar := "systemd:\n  units:\n   - name: bar"
+	foo := fmt.Sprintf(""+
+		"import:\n"+
+		"  - file: bar.yaml\n"+
+		"    hash: %s%s\n"+
+		"systemd:\n"+
+		"  units:\n"+
+		"   - name: {{.name}}",
+		hashPrefix, hashSum([]byte(bar)),
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/base/foo.yaml":
+			fmt.Fprint(w, foo)
+		case "/base/bar.yaml":
+			fmt.Fprint(w, bar)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	input := []byte(fmt.Sprintf(""+
+		"---\n"+
+		"import:\n"+
+		"  - file: %s/base/foo.yaml\n"+
+		"    hash: %s%s\n"+
+		"    values:\n"+
+		"      name: foo\n",
+		s.URL, hashPrefix, hashSum([]byte(foo)),
+	))
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, []string{"foo", "bar"}, unitNames(c))
+}
+
+func TestConfigResolveRemoteMissingHash(t *testing.T) {
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - https://localhost/foo.yaml\n",
+	)
+
+	_, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.Error(t, err)
+}
+
+func TestJoinPath(t *testing.T) {
+	for _, c := range [][]string{
+		{"foo", "bar.yaml", "foo/bar.yaml"},
+		{"foo", "https://foo/qux.yaml", "https://foo/qux.yaml"},
+		{"https://foo/bar/", "qux.yaml", "https://foo/bar/qux.yaml"},
+		{"https://foo/bar/", "../qux.yaml", "https://foo/qux.yaml"},
+	} {
+		path, err := joinPath(c[0], c[1])
+		assert.NoError(t, err)
+		assert.Equal(t, c[2], path)
+	}
+}
+
+func TestCacheFetch(t *testing.T) {
+	content := "foo"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer s.Close()
+
+	c := &Cache{Dir: "cache/fetch"}
+	data, hash, err := c.Fetch(s.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(data))
+	assert.Equal(t, hashPrefix+hashSum([]byte("foo")), hash)
+
+	content = "bar"
+	data, hash, err = c.Fetch(s.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	assert.Equal(t, hashPrefix+hashSum([]byte("bar")), hash)
+
+	c.Offline = true
+	s.Close()
+	data, hash, err = c.Fetch(s.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	assert.Equal(t, hashPrefix+hashSum([]byte("bar")), hash)
+
+	_, _, err = c.Fetch("http://localhost/missing")
+	assert.Error(t, err)
+}
+
+func TestConfigRemoteFiles(t *testing.T) {
+	defer func(c *Cache) { DefaultCache = c }(DefaultCache)
+	DefaultCache = &Cache{Dir: "cache/files"}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "foo")
+	}))
+	defer s.Close()
+
+	input := []byte("" +
+		"---\n" +
+		"storage:\n" +
+		"  files:\n" +
+		"    - path: /foo\n" +
+		"      contents:\n" +
+		"        remote:\n" +
+		"          url: " + s.URL + "/foo\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", c.Storage.Files[0].Contents.Remote.Verification.Hash.Sum)
+
+	c, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil, PinRemoteFiles())
+	assert.NoError(t, err)
+
+	contents := c.Storage.Files[0].Contents
+	assert.Equal(t, s.URL+"/foo", contents.Remote.Url)
+	assert.Equal(t, "sha512", contents.Remote.Verification.Hash.Function)
+	assert.Equal(t, hashSum([]byte("foo")), contents.Remote.Verification.Hash.Sum)
+
+	c, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil, InlineRemoteFiles())
+	assert.NoError(t, err)
+
+	contents = c.Storage.Files[0].Contents
+	assert.Equal(t, "", contents.Remote.Url)
+	assert.Equal(t, "", contents.Remote.Verification.Hash.Sum)
+	assert.Equal(t, "foo", contents.Inline)
+
+	stale := append(input, []byte(""+
+		"          verification:\n"+
+		"            hash:\n"+
+		"              function: sha512\n"+
+		"              sum: "+hashSum([]byte("bar"))+"\n",
+	)...)
+
+	_, err = NewConfig(bytes.NewBuffer(stale), "fixtures/inline.yaml", nil, PinRemoteFiles())
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package combustion
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// SecretsKeyEnv is the environment variable with the key of the secrets store,
+// it's never readable from the templates, neither with the env function
+const SecretsKeyEnv = "COMBUSTION_SECRETS_KEY"
+
+// SecretStore is a local file of secrets, every secret is encrypted with
+// AES-256-GCM using a key derived from the given one, with its name as
+// additional data, so the encrypted values can't be swapped between names. The
+// file is a YAML map of the secret names to the encrypted values, so the names
+// can be read and reviewed without the key.
+type SecretStore struct {
+	// Filename of the store
+	Filename string
+
+	key     []byte
+	secrets map[string]string
+}
+
+// NewSecretStore opens the store at filename with the given key, every secret
+// is decrypted, so a wrong key is reported here. If the file doesn't exist an
+// empty store is returned, created on Save. The AES key is the SHA-256 of the
+// given key, without salt nor key stretching, so the key should be random
+// bytes with high entropy, never a passphrase.
+func NewSecretStore(filename string, key []byte) (*SecretStore, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty secrets key")
+	}
+
+	sum := sha256.Sum256(key)
+	s := &SecretStore{
+		Filename: filename,
+		key:      sum[:],
+		secrets:  make(map[string]string),
+	}
+
+	content, err := s.read()
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted map[string]string
+	if err := yaml.Unmarshal(content, &encrypted); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	for name, value := range encrypted {
+		secret, err := s.decrypt(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to decrypt secret %q: %s", filename, name, err)
+		}
+
+		s.secrets[name] = secret
+	}
+
+	return s, nil
+}
+
+// ReadSecretKey returns the key contained in the given file, without the
+// surrounding white spaces
+func ReadSecretKey(filename string) ([]byte, error) {
+	f, err := FileSystem.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.TrimSpace(string(content))), nil
+}
+
+// Get returns the secret with the given name
+func (s *SecretStore) Get(name string) (string, error) {
+	secret, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found in %q", name, s.Filename)
+	}
+
+	return secret, nil
+}
+
+// Has returns true if the store contains a secret with the given name
+func (s *SecretStore) Has(name string) bool {
+	_, ok := s.secrets[name]
+	return ok
+}
+
+// Set adds or replaces the secret with the given name, the store should be
+// saved to persist the change
+func (s *SecretStore) Set(name, secret string) {
+	s.secrets[name] = secret
+}
+
+// Names returns the sorted names of the secrets
+func (s *SecretStore) Names() []string {
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the store to its file, every secret is encrypted again
+func (s *SecretStore) Save() error {
+	encrypted := make(map[string]string, len(s.secrets))
+	for name, secret := range s.secrets {
+		value, err := s.encrypt(name, secret)
+		if err != nil {
+			return err
+		}
+
+		encrypted[name] = value
+	}
+
+	content, err := yaml.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+
+	f, err := FileSystem.Create(s.Filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *SecretStore) read() ([]byte, error) {
+	f, err := FileSystem.Open(s.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// encrypt returns the base64 encoded nonce followed by the sealed secret, the
+// name is authenticated as additional data
+func (s *SecretStore) encrypt(name, secret string) (string, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *SecretStore) decrypt(name, value string) (string, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value")
+	}
+
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func (s *SecretStore) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
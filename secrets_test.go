@@ -0,0 +1,90 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v1"
+)
+
+func TestSecretStore(t *testing.T) {
+	s, err := NewSecretStore("fixtures/secrets/store.yaml", []byte("key"))
+	assert.NoError(t, err)
+	assert.Len(t, s.Names(), 0)
+
+	s.Set("password", "foo")
+	s.Set("token", "bar")
+	assert.NoError(t, s.Save())
+
+	content, err := (&SecretStore{Filename: s.Filename}).read()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "foo")
+
+	s, err = NewSecretStore("fixtures/secrets/store.yaml", []byte("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"password", "token"}, s.Names())
+	assert.True(t, s.Has("password"))
+
+	secret, err := s.Get("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", secret)
+
+	_, err = s.Get("missing")
+	assert.Error(t, err)
+
+	_, err = NewSecretStore("fixtures/secrets/store.yaml", []byte("wrong"))
+	assert.Error(t, err)
+}
+
+func TestSecretStoreSwapped(t *testing.T) {
+	s, err := NewSecretStore("fixtures/secrets/swapped.yaml", []byte("key"))
+	assert.NoError(t, err)
+
+	s.Set("password", "foo")
+	s.Set("token", "bar")
+	assert.NoError(t, s.Save())
+
+	content, err := s.read()
+	assert.NoError(t, err)
+
+	var encrypted map[string]string
+	assert.NoError(t, yaml.Unmarshal(content, &encrypted))
+	encrypted["password"], encrypted["token"] = encrypted["token"], encrypted["password"]
+
+	content, err = yaml.Marshal(encrypted)
+	assert.NoError(t, err)
+	WriteFixture(s.Filename, string(content))
+
+	_, err = NewSecretStore("fixtures/secrets/swapped.yaml", []byte("key"))
+	assert.Regexp(t, "unable to decrypt secret", err)
+}
+
+func TestReadSecretKey(t *testing.T) {
+	WriteFixture("fixtures/secrets/key", "foo\n")
+
+	key, err := ReadSecretKey("fixtures/secrets/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(key))
+}
+
+func TestConfigSecret(t *testing.T) {
+	s, err := NewSecretStore("fixtures/secrets/config.yaml", []byte("key"))
+	assert.NoError(t, err)
+	s.Set("password", "foo")
+
+	input := []byte("" +
+		"---\n" +
+		"systemd:\n" +
+		"  units:\n" +
+		"   - name: {{ secret \"password\" }}\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil, Secrets(s))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(c.Config.Systemd.Units[0].Name))
+
+	_, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+package combustion
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// syntax holds the template delimiters used by a file: the delimiters of the
+// combustion actions, executed when the file is interpolated, and the
+// passthrough delimiters, translated into {{ }} in the output, so the actions
+// can be executed later by other tools, like matchbox.
+type syntax struct {
+	Left, Right string
+	// PassLeft and PassRight are empty when the passthrough is disabled
+	PassLeft, PassRight string
+	// Late is false when the late actions are disabled
+	Late bool
+}
+
+var defaultSyntax = syntax{
+	Left: "{{", Right: "}}", PassLeft: "{%", PassRight: "%}", Late: true,
+}
+
+// directive matches the lines like "# combustion:delims [[ ]]"
+var directive = regexp.MustCompile(`^#\s*combustion:(\S+)(.*)$`)
+
+// newSyntax reads the syntax directives from the header of the content, the
+// comments and blank lines before any other content. The directives are
+// removed from the returned content, keeping the line numbers.
+func newSyntax(content []byte) (*syntax, []byte, error) {
+	s := defaultSyntax
+	lines := bytes.Split(content, []byte("\n"))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(string(line))
+		if trimmed != "" && trimmed != "---" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+
+		m := directive.FindStringSubmatch(trimmed)
+		if m == nil {
+			continue
+		}
+
+		if err := s.set(m[1], strings.Fields(m[2])); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+
+		lines[i] = []byte("#")
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &s, bytes.Join(lines, []byte("\n")), nil
+}
+
+func (s *syntax) set(name string, args []string) error {
+	switch name {
+	case "delims":
+		if len(args) != 2 {
+			return fmt.Errorf("invalid delims directive, expected <left> <right>")
+		}
+
+		s.Left, s.Right = args[0], args[1]
+	case "passthrough":
+		if len(args) == 1 && args[0] == "off" {
+			s.PassLeft, s.PassRight = "", ""
+			return nil
+		}
+
+		if len(args) != 2 {
+			return fmt.Errorf("invalid passthrough directive, expected <left> <right> or off")
+		}
+
+		s.PassLeft, s.PassRight = args[0], args[1]
+	case "late":
+		if len(args) != 1 || args[0] != "off" {
+			return fmt.Errorf("invalid late directive, expected off")
+		}
+
+		s.Late = false
+	default:
+		return fmt.Errorf("unknown directive %q", name)
+	}
+
+	return nil
+}
+
+func (s *syntax) validate() error {
+	if s.Late && (s.Left == lateLeft || s.PassLeft == lateLeft) {
+		return fmt.Errorf("%s is reserved for the late actions", lateLeft)
+	}
+
+	if s.PassLeft == "" {
+		return nil
+	}
+
+	if s.PassLeft == s.Left || s.PassRight == s.Right {
+		return fmt.Errorf("passthrough delimiters can't be the same as the delimiters")
+	}
+
+	return nil
+}
+
+// passthrough replaces, in the template source, every passthrough action with
+// an action returning {{ and }} around the content, so the result of the
+// template contains the action with the {{ }} delimiters. A passthrough
+// delimiter preceded by a backslash, like \{%, is not translated and is
+// written without the backslash.
+func (s *syntax) passthrough(content []byte) ([]byte, []edit) {
+	if s.PassLeft == "" {
+		return content, nil
+	}
+
+	open := []byte(s.Left + `"{{"` + s.Right)
+	end := []byte(s.Left + `"}}"` + s.Right)
+
+	return replaceAll(s.passthroughRegexp(), content, func(match []byte) []byte {
+		if match[0] == '\\' {
+			return match[1:]
+		}
+
+		inner := match[len(s.PassLeft) : len(match)-len(s.PassRight)]
+
+		r := make([]byte, 0, len(open)+len(inner)+len(end))
+		r = append(r, open...)
+		r = append(r, inner...)
+		return append(r, end...)
+	})
+}
+
+// passthroughActions returns the passthrough actions of the content, with the
+// {{ }} delimiters and without the text between them
+func (s *syntax) passthroughActions(content []byte) string {
+	if s.PassLeft == "" {
+		return ""
+	}
+
+	var actions []string
+	for _, m := range s.passthroughRegexp().FindAllSubmatch(content, -1) {
+		if m[0][0] != '\\' {
+			actions = append(actions, "{{"+string(m[1])+"}}")
+		}
+	}
+
+	return strings.Join(actions, "")
+}
+
+// passthroughRegexp matches the passthrough actions and the escaped
+// passthrough delimiters
+func (s *syntax) passthroughRegexp() *regexp.Regexp {
+	left, right := regexp.QuoteMeta(s.PassLeft), regexp.QuoteMeta(s.PassRight)
+	return regexp.MustCompile(`\\` + left + `|\\` + right + `|` + left + `(.+?)` + right)
+}
+
+// translate returns the template source of the content, with the late actions,
+// when late is true, and the passthrough actions translated, and the map of
+// the template source to the content
+func (s *syntax) translate(content []byte, late bool) ([]byte, *sourceMap) {
+	m := &sourceMap{source: content}
+
+	src := content
+	if late {
+		var edits []edit
+		src, edits = s.late(src)
+		m.layers = append(m.layers, edits)
+	}
+
+	src, edits := s.passthrough(src)
+	m.layers = append(m.layers, edits)
+	m.result = src
+	return src, m
+}
+
+// edit is a replacement done translating the template source
+type edit struct {
+	// offset of the replacement in the result
+	offset int
+	// from and to are the length of the replaced text and of the replacement
+	from, to int
+}
+
+// replaceAll is like regexp.ReplaceAllFunc, returning also the edits done
+func replaceAll(re *regexp.Regexp, content []byte, fn func([]byte) []byte) ([]byte, []edit) {
+	var r []byte
+	var edits []edit
+	var last int
+	for _, loc := range re.FindAllIndex(content, -1) {
+		r = append(r, content[last:loc[0]]...)
+		replacement := fn(content[loc[0]:loc[1]])
+		edits = append(edits, edit{offset: len(r), from: loc[1] - loc[0], to: len(replacement)})
+		r = append(r, replacement...)
+		last = loc[1]
+	}
+
+	if edits == nil {
+		return content, nil
+	}
+
+	return append(r, content[last:]...), edits
+}
+
+// sourceMap maps the positions of a translated template source to the
+// positions of the content of the file, the translations never add or remove
+// lines, so only the columns change
+type sourceMap struct {
+	source, result []byte
+	// layers are the edits of every translation, in the order they are done
+	layers [][]edit
+}
+
+// errConfig returns the template error as an ErrConfig, with the column of
+// the error in the content of the file
+func (m *sourceMap) errConfig(filename string, err error) *ErrConfig {
+	e := newErrConfig(filename, nil, err)
+	if e.Line != 0 && e.Column != 0 {
+		e.Column = m.column(e.Line, e.Column)
+	}
+
+	return e
+}
+
+// column returns the column in the content of a column of the template source
+func (m *sourceMap) column(line, column int) int {
+	offset := lineOffset(m.result, line) + column
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		offset = unedit(m.layers[i], offset)
+	}
+
+	return offset - lineOffset(m.source, line)
+}
+
+// unedit returns the offset, before the edits, of an offset of the result, the
+// offsets inside of a replacement are moved to the start of the replaced text
+func unedit(edits []edit, offset int) int {
+	var delta int
+	for _, e := range edits {
+		if offset < e.offset {
+			break
+		}
+
+		if offset < e.offset+e.to {
+			return e.offset - delta
+		}
+
+		delta += e.to - e.from
+	}
+
+	return offset - delta
+}
+
+// lineOffset returns the offset of the start of the given line, starting at 1
+func lineOffset(content []byte, line int) int {
+	var offset int
+	for i := 1; i < line; i++ {
+		n := bytes.IndexByte(content[offset:], '\n')
+		if n == -1 {
+			return len(content)
+		}
+
+		offset += n + 1
+	}
+
+	return offset
+}
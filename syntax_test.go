@@ -0,0 +1,117 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSyntax(t *testing.T) {
+	s, content, err := newSyntax([]byte("" +
+		"---\n" +
+		"# combustion:delims [[ ]]\n" +
+		"#combustion:passthrough [% %]\n" +
+		"# combustion:late off\n" +
+		"foo: bar\n" +
+		"# combustion:passthrough off\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &syntax{Left: "[[", Right: "]]", PassLeft: "[%", PassRight: "%]"}, s)
+	assert.Equal(t, "---\n#\n#\n#\nfoo: bar\n# combustion:passthrough off\n", string(content))
+}
+
+func TestNewSyntaxDefault(t *testing.T) {
+	s, _, err := newSyntax([]byte("foo: bar\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &defaultSyntax, s)
+}
+
+func TestNewSyntaxInvalid(t *testing.T) {
+	for _, content := range []string{
+		"# combustion:delims [[\n",
+		"# combustion:passthrough foo\n",
+		"# combustion:foo bar\n",
+		"# combustion:passthrough {{ }}\n",
+		"# combustion:passthrough <% %>\n",
+		"# combustion:delims <% %>\n",
+		"# combustion:late on\n",
+	} {
+		_, _, err := newSyntax([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestConfigInterpolateSyntax(t *testing.T) {
+	for _, c := range []struct {
+		content string
+		result  string
+	}{{
+		"name: {{ .foo }} {% .bar %} \\{% raw \\%} \\{% .bar %}",
+		"name: qux {{ .bar }} {% raw %} {% .bar %}",
+	}, {
+		"# combustion:delims [[ ]]\nname: [[ .foo ]] {{ .bar }} {% .bar %}",
+		"#\nname: qux {{ .bar }} {{ .bar }}",
+	}, {
+		"# combustion:passthrough [% %]\nname: {{ .foo }} [% .bar %] {% raw %}",
+		"#\nname: qux {{ .bar }} {% raw %}",
+	}, {
+		"# combustion:passthrough off\nname: {{ .foo }} {% raw %}",
+		"#\nname: qux {% raw %}",
+	}} {
+		cfg := &Config{name: "foo.yaml"}
+		result, err := cfg.interpolate([]byte(c.content), Values{"foo": "qux"})
+		assert.NoError(t, err, c.content)
+		assert.Equal(t, c.result, string(result), c.content)
+	}
+}
+
+func TestSourceMapColumn(t *testing.T) {
+	s := defaultSyntax
+	content := []byte("foo\nname: {% .a %} <% .b %> \\{% {{ .c }}\n")
+	src, m := s.translate(content, true)
+
+	line := string(bytes.Split(src, []byte("\n"))[1])
+	column := bytes.Index([]byte(line), []byte(".c"))
+	assert.Equal(t, 31, m.column(2, column))
+	assert.Equal(t, 6, m.column(2, 6))
+	assert.Equal(t, 6, m.column(2, 8))
+}
+
+func TestConfigInterpolateSyntaxJinja(t *testing.T) {
+	input := []byte("" +
+		"# combustion:passthrough off\n" +
+		"storage:\n" +
+		"  files:\n" +
+		"    - path: /etc/foo.j2\n" +
+		"      contents:\n" +
+		"        inline: |\n" +
+		"          {% for host in hosts %}{{ .foo }}{% endfor %}\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"foo": "bar"})
+	assert.NoError(t, err)
+	assert.Equal(t, "{% for host in hosts %}bar{% endfor %}\n", c.Storage.Files[0].Contents.Inline)
+
+	// the {{ }} of Jinja require changing the delimiters of the combustion actions
+	input = []byte("" +
+		"# combustion:delims [[ ]]\n" +
+		"# combustion:passthrough off\n" +
+		"storage:\n" +
+		"  files:\n" +
+		"    - path: /etc/foo.j2\n" +
+		"      contents:\n" +
+		"        inline: |\n" +
+		"          {% for host in hosts %}{{ host }} [[ .foo ]]{% endfor %}\n" +
+		"",
+	)
+
+	c, err = NewConfig(bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"foo": "bar"})
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"{% for host in hosts %}{{ host }} bar{% endfor %}\n",
+		c.Storage.Files[0].Contents.Inline,
+	)
+}
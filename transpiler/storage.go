@@ -1,7 +1,9 @@
 package transpiler
 
 import (
+	"encoding/base64"
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/coreos/coreos-cloudinit/config"
 	"github.com/coreos/ignition/config/types"
@@ -57,7 +59,7 @@ func (t *ccTranspiler) doStorageFile(idx int, f *types.File) {
 		owner = fmt.Sprintf("%d", f.User.Id)
 	}
 
-	var content string
+	var data []byte
 	if f.Contents.Source.Scheme != "" {
 		url, err := dataurl.DecodeString(f.Contents.Source.String())
 		if err != nil {
@@ -65,10 +67,16 @@ func (t *ccTranspiler) doStorageFile(idx int, f *types.File) {
 			return
 		}
 
-		content = string(url.Data)
+		data = url.Data
+	}
+
+	content, encoding, ok := t.encodeContent(idx, f.Contents.Compression, data)
+	if !ok {
+		return
 	}
 
 	t.cc.WriteFiles = append(t.cc.WriteFiles, config.File{
+		Encoding:           encoding,
 		Content:            content,
 		Path:               string(f.Path),
 		Owner:              owner,
@@ -76,3 +84,25 @@ func (t *ccTranspiler) doStorageFile(idx int, f *types.File) {
 	})
 
 }
+
+// encodeContent returns the content of a file and its write_files encoding,
+// the compressed and not valid UTF-8 contents are base64 encoded
+func (t *ccTranspiler) encodeContent(idx int, compression types.Compression, data []byte) (
+	content, encoding string, ok bool,
+) {
+	switch {
+	case compression == "gzip":
+		return base64.StdEncoding.EncodeToString(data), "gzip+base64", true
+	case compression != "":
+		t.ignoredEntry(
+			fmt.Sprintf("storage.files[%d]", idx),
+			fmt.Sprintf("unsupported compression %q", compression),
+		)
+
+		return "", "", false
+	case !utf8.Valid(data):
+		return base64.StdEncoding.EncodeToString(data), "b64", true
+	default:
+		return string(data), "", true
+	}
+}
@@ -75,3 +75,24 @@ func TestDoStorageFilesystems(t *testing.T) {
 	assert.NotNil(t, cc)
 	assert.Equal(t, "storage.filesystems is not supported in cloud-config", r.Entries[0].Message)
 }
+
+func TestDoStorageEncoding(t *testing.T) {
+	binary, _ := url.Parse("data:;base64,/w==")
+	gzip, _ := url.Parse("data:;base64,H4sI")
+	c := &types.Config{}
+	c.Storage.Files = []types.File{{
+		Contents: types.FileContents{Source: types.Url(*binary)},
+	}, {
+		Contents: types.FileContents{Source: types.Url(*gzip), Compression: "gzip"},
+	}, {
+		Contents: types.FileContents{Source: types.Url(*gzip), Compression: "bzip2"},
+	}}
+
+	cc, r := TranspileIgnition(c)
+	assert.Equal(t, 2, len(cc.WriteFiles))
+	assert.Equal(t, "b64", cc.WriteFiles[0].Encoding)
+	assert.Equal(t, "/w==", cc.WriteFiles[0].Content)
+	assert.Equal(t, "gzip+base64", cc.WriteFiles[1].Encoding)
+	assert.Equal(t, "H4sI", cc.WriteFiles[1].Content)
+	assert.Equal(t, 1, len(r.Entries))
+}
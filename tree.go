@@ -0,0 +1,171 @@
+package combustion
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/coreos/container-linux-config-transpiler/config/types"
+	"gopkg.in/yaml.v1"
+)
+
+// Tree is a local directory mirrored into the storage, every file is added to
+// storage.files and every directory to storage.directories
+type Tree struct {
+	// Local directory, relative to the config
+	Local string `yaml:"local"`
+	// Path where the directory is mirrored
+	Path string `yaml:"path"`
+	// Filesystem of the files and directories, root by default
+	Filesystem string `yaml:"filesystem,omitempty"`
+	// Mode of the files, if zero the mode of the local file is used
+	Mode int `yaml:"mode,omitempty"`
+	// DirMode mode of the directories, if zero the mode of the local
+	// directory is used
+	DirMode int `yaml:"dir_mode,omitempty"`
+	// User and Group owning the files and directories
+	User  types.FileUser  `yaml:"user,omitempty"`
+	Group types.FileGroup `yaml:"group,omitempty"`
+}
+
+// newTrees decodes the storage.trees key of the given YAML content
+func newTrees(y []byte) ([]*Tree, error) {
+	var raw struct {
+		Storage struct {
+			Trees []*Tree `yaml:"trees"`
+		} `yaml:"storage"`
+	}
+
+	if err := yaml.Unmarshal(y, &raw); err != nil {
+		return nil, err
+	}
+
+	for i, t := range raw.Storage.Trees {
+		if t.Local == "" || t.Path == "" {
+			return nil, fmt.Errorf("invalid storage.trees[%d], local and path are required", i)
+		}
+
+		if !path.IsAbs(t.Path) {
+			return nil, fmt.Errorf("invalid storage.trees[%d], path %q should be absolute", i, t.Path)
+		}
+
+		if t.Filesystem == "" {
+			t.Filesystem = "root"
+		}
+	}
+
+	return raw.Storage.Trees, nil
+}
+
+// loadTrees adds to the storage the files and directories of the trees
+func (c *Config) loadTrees() error {
+	if len(c.Trees) != 0 && (isRemote(c.dir) || isGit(c.dir)) {
+		return fmt.Errorf("storage.trees are not supported in remote or git file %q", c.name)
+	}
+
+	for _, t := range c.Trees {
+		local := filepath.Join(c.dir, filepath.FromSlash(t.Local))
+		if !c.options.inRoot(local) {
+			return fmt.Errorf("tree %q is outside of the root %q", t.Local, c.options.root)
+		}
+
+		fi, err := FileSystem.Stat(local)
+		if err != nil {
+			return fmt.Errorf("error opening tree %q: %s", t.Local, err)
+		}
+
+		if !fi.IsDir() {
+			return fmt.Errorf("tree %q is not a directory", t.Local)
+		}
+
+		if t.Path != "/" {
+			c.addTreeDirectory(t, t.Path, fi)
+		}
+
+		if err := c.walkTree(t, local, path.Clean(t.Path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkTree adds the content of the local directory, sorted by name, to the
+// storage at the given path
+func (c *Config) walkTree(t *Tree, local, target string) error {
+	entries, err := FileSystem.ReadDir(local)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, e := range entries {
+		filename := filepath.Join(local, e.Name())
+		p := path.Join(target, e.Name())
+		if !c.options.inRoot(filename) {
+			return fmt.Errorf("%q of tree %q is outside of the root %q", p, t.Local, c.options.root)
+		}
+
+		if e.IsDir() {
+			c.addTreeDirectory(t, p, e)
+			if err := c.walkTree(t, filename, p); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := c.addTreeFile(t, filename, p, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) addTreeDirectory(t *Tree, p string, fi os.FileInfo) {
+	mode := t.DirMode
+	if mode == 0 {
+		mode = int(fi.Mode().Perm())
+	}
+
+	c.Storage.Directories = append(c.Storage.Directories, types.Directory{
+		Filesystem: t.Filesystem,
+		Path:       p,
+		Mode:       mode,
+		User:       t.User,
+		Group:      t.Group,
+	})
+}
+
+func (c *Config) addTreeFile(t *Tree, filename, p string, fi os.FileInfo) error {
+	content, err := c.doLoadLocalFile(filename)
+	if err != nil {
+		return err
+	}
+
+	mode := t.Mode
+	if mode == 0 {
+		mode = int(fi.Mode().Perm())
+	}
+
+	f := types.File{
+		Filesystem: t.Filesystem,
+		Path:       p,
+		Mode:       mode,
+		User:       t.User,
+		Group:      t.Group,
+	}
+
+	if err := setLocalContents(&f.Contents, content); err != nil {
+		return err
+	}
+
+	c.Storage.Files = append(c.Storage.Files, f)
+	return nil
+}
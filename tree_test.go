@@ -0,0 +1,112 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigTrees(t *testing.T) {
+	WriteFixtures([][]string{
+		{"fixtures/trees/rootfs/etc/foo.conf", "foo"},
+		{"fixtures/trees/rootfs/etc/bar/bar.conf", "bar"},
+		{"fixtures/trees/rootfs/qux", "qux"},
+	})
+
+	input := []byte("" +
+		"---\n" +
+		"storage:\n" +
+		"  trees:\n" +
+		"    - local: rootfs\n" +
+		"      path: /opt/app\n" +
+		"      mode: 0644\n" +
+		"      dir_mode: 0755\n" +
+		"      user:\n" +
+		"        id: 500\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/trees/inline.yaml", nil)
+	assert.NoError(t, err)
+
+	var dirs []string
+	for _, d := range c.Storage.Directories {
+		assert.Equal(t, 0755, d.Mode)
+		assert.Equal(t, "root", d.Filesystem)
+		dirs = append(dirs, d.Path)
+	}
+
+	assert.Equal(t, []string{"/opt/app", "/opt/app/etc", "/opt/app/etc/bar"}, dirs)
+
+	var files []string
+	for _, f := range c.Storage.Files {
+		assert.Equal(t, 0644, f.Mode)
+		assert.Equal(t, 500, f.User.Id)
+		files = append(files, f.Path+"="+f.Contents.Inline)
+	}
+
+	assert.Equal(t, []string{
+		"/opt/app/etc/bar/bar.conf=bar",
+		"/opt/app/etc/foo.conf=foo",
+		"/opt/app/qux=qux",
+	}, files)
+}
+
+func TestConfigTreesLocalMode(t *testing.T) {
+	WriteFixture("fixtures/trees/mode/foo", "foo")
+	fi, err := FileSystem.Stat("fixtures/trees/mode/foo")
+	assert.NoError(t, err)
+
+	input := []byte("" +
+		"---\n" +
+		"storage:\n" +
+		"  trees:\n" +
+		"    - local: mode\n" +
+		"      path: /\n" +
+		"",
+	)
+
+	c, err := NewConfig(bytes.NewBuffer(input), "fixtures/trees/inline.yaml", nil)
+	assert.NoError(t, err)
+	assert.Len(t, c.Storage.Directories, 0)
+	assert.Equal(t, "/foo", c.Storage.Files[0].Path)
+	assert.Equal(t, int(fi.Mode().Perm()), c.Storage.Files[0].Mode)
+}
+
+func TestConfigTreesInvalid(t *testing.T) {
+	WriteFixture("fixtures/trees/file", "foo")
+
+	for _, tree := range []string{
+		"local: missing\n      path: /",
+		"local: file\n      path: /",
+		"local: rootfs\n      path: relative",
+		"path: /",
+	} {
+		input := []byte("" +
+			"---\n" +
+			"storage:\n" +
+			"  trees:\n" +
+			"    - " + tree + "\n" +
+			"",
+		)
+
+		_, err := NewConfig(bytes.NewBuffer(input), "fixtures/trees/inline.yaml", nil)
+		assert.Error(t, err, tree)
+	}
+
+	input := []byte("" +
+		"---\n" +
+		"storage:\n" +
+		"  trees:\n" +
+		"    - local: ../../\n" +
+		"      path: /\n" +
+		"",
+	)
+
+	_, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/trees/inline.yaml", nil, Root("fixtures/trees"),
+	)
+
+	assert.Error(t, err)
+}
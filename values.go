@@ -0,0 +1,200 @@
+package combustion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v1"
+)
+
+// privatePrefix is the prefix of the private values, the values only visible
+// to the file receiving them and never inherited by its imports
+const privatePrefix = "_"
+
+// Values interpolation values to replace on the Config, the values keep the
+// YAML types, so they can be strings, numbers, booleans, lists or maps
+type Values map[string]interface{}
+
+// NewValuesFromFile returns the Values from the given YAML file, the file
+// should contain a map
+func NewValuesFromFile(filename string) (Values, error) {
+	f, err := FileSystem.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	var literals map[string]string
+	if err := yaml.Unmarshal(content, &literals); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	v, err := newValues(raw, literals)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return v, nil
+}
+
+// NewValuesFromEnv returns the Values from the environment variables with the
+// given prefix, the name of the value is the name of the variable without the
+// prefix and in lower case, the values are parsed as with Set. The variables
+// in ignore, like the ones used to configure combustion, are skipped.
+func NewValuesFromEnv(prefix string, ignore ...string) (Values, error) {
+	skip := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		skip[name] = true
+	}
+
+	v := make(Values)
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(env, prefix) || skip[name] {
+			continue
+		}
+
+		parts := strings.SplitN(env[len(prefix):], "=", 2)
+		if err := v.Set(strings.ToLower(parts[0]) + "=" + parts[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// Set sets a value from an expression like key=value, the value is parsed as
+// a YAML value, so it can be any type, like list=[a, b] or enabled=true
+func (v Values) Set(expr string) error {
+	parts := strings.SplitN(expr, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid value %q, expected key=value", expr)
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(parts[1]), &raw); err != nil {
+		return fmt.Errorf("invalid value %q: %s", expr, err)
+	}
+
+	var literal string
+	if isScalar(raw) {
+		if err := yaml.Unmarshal([]byte(parts[1]), &literal); err != nil {
+			return fmt.Errorf("invalid value %q: %s", expr, err)
+		}
+	}
+
+	v[parts[0]] = literalValue(raw, literal, isScalar(raw))
+	return nil
+}
+
+// Merge sets all the values from o into v, replacing the existing ones
+func (v Values) Merge(o Values) {
+	for k, value := range o {
+		v[k] = value
+	}
+}
+
+// newValues returns the Values from a YAML map decoded into raw, the nested
+// maps are converted to map[string]interface{}. The literals are the same map
+// decoded as strings, any scalar written in a way that doesn't match its
+// string representation, like 1.10, 0644 or yes, is kept as the literal
+// string, as it was when the values were strings.
+func newValues(raw interface{}, literals map[string]string) (Values, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	m, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid values, expected a map")
+	}
+
+	v := make(Values, len(m))
+	for k, value := range m {
+		key := fmt.Sprint(k)
+		literal, ok := literals[key]
+		v[key] = literalValue(value, literal, ok)
+	}
+
+	return v, nil
+}
+
+// literalValue returns the value to be used for a value decoded from YAML, if
+// the value is a scalar with a literal not matching its string representation
+// the literal is returned
+func literalValue(value interface{}, literal string, hasLiteral bool) interface{} {
+	if hasLiteral && isScalar(value) {
+		if value == nil || fmt.Sprint(value) != literal {
+			return literal
+		}
+	}
+
+	if value == nil {
+		return ""
+	}
+
+	return normalizeValue(value)
+}
+
+// normalizeValue converts any map[interface{}]interface{}, as decoded by the
+// yaml package, to a map[string]interface{}
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(k)] = normalizeValue(value)
+		}
+
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = normalizeValue(value)
+		}
+
+		return s
+	default:
+		return value
+	}
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+		return false
+	default:
+		return true
+	}
+}
+
+// inherit returns the values seen by a file imported with the given values,
+// the non private values of v overridden by the import values
+func (v Values) inherit(values Values) Values {
+	r := make(Values, len(v)+len(values))
+	for k, value := range v {
+		if strings.HasPrefix(k, privatePrefix) {
+			continue
+		}
+
+		r[k] = value
+	}
+
+	for k, value := range values {
+		r[k] = value
+	}
+
+	return r
+}
@@ -0,0 +1,74 @@
+package combustion
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesInherit(t *testing.T) {
+	v := Values{"foo": "foo", "bar": "bar", "_qux": "qux"}
+	r := v.inherit(Values{"bar": "baz", "_secret": "secret"})
+
+	assert.Equal(t, Values{"foo": "foo", "bar": "baz", "_secret": "secret"}, r)
+	assert.Equal(t, Values{"foo": "foo", "bar": "bar", "_qux": "qux"}, v)
+}
+
+func TestNewValuesFromFile(t *testing.T) {
+	WriteFixture("fixtures/values.yaml", ""+
+		"channel: stable\n"+
+		"version: 1.10\n"+
+		"count: 3\n"+
+		"keys: [foo, bar]\n",
+	)
+
+	v, err := NewValuesFromFile("fixtures/values.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, Values{
+		"channel": "stable",
+		"version": "1.10",
+		"count":   3,
+		"keys":    []interface{}{"foo", "bar"},
+	}, v)
+}
+
+func TestNewValuesFromEnv(t *testing.T) {
+	os.Setenv("COMBUSTION_TEST_CHANNEL", "Stable")
+	os.Setenv("COMBUSTION_TEST_COUNT", "3")
+	os.Setenv("COMBUSTION_TEST_KEY", "a: [b")
+	defer os.Unsetenv("COMBUSTION_TEST_CHANNEL")
+	defer os.Unsetenv("COMBUSTION_TEST_COUNT")
+	defer os.Unsetenv("COMBUSTION_TEST_KEY")
+
+	v, err := NewValuesFromEnv("COMBUSTION_TEST_", "COMBUSTION_TEST_KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, Values{"channel": "Stable", "count": 3}, v)
+}
+
+func TestValuesSet(t *testing.T) {
+	v := make(Values)
+	assert.NoError(t, v.Set("foo=bar"))
+	assert.NoError(t, v.Set("count=3"))
+	assert.NoError(t, v.Set("version=1.10"))
+	assert.NoError(t, v.Set("keys=[a, b]"))
+	assert.NoError(t, v.Set("empty="))
+	assert.NoError(t, v.Set("url=http://foo/?a=b"))
+	assert.Error(t, v.Set("foo"))
+
+	assert.Equal(t, Values{
+		"foo":     "bar",
+		"count":   3,
+		"version": "1.10",
+		"keys":    []interface{}{"a", "b"},
+		"empty":   "",
+		"url":     "http://foo/?a=b",
+	}, v)
+}
+
+func TestValuesMerge(t *testing.T) {
+	v := Values{"foo": "foo", "bar": "bar"}
+	v.Merge(Values{"bar": "qux"})
+
+	assert.Equal(t, Values{"foo": "foo", "bar": "qux"}, v)
+}
@@ -0,0 +1,317 @@
+package combustion
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/coreos/ignition/config/validate/report"
+)
+
+// Status of the variables referenced by a file
+const (
+	// VarProvided the value is given to the file by its import
+	VarProvided = "provided"
+	// VarInherited the value is inherited from the importing files
+	VarInherited = "inherited"
+	// VarDefault the value is the default of the param
+	VarDefault = "default"
+	// VarMissing the value is never provided
+	VarMissing = "missing"
+	// VarRuntime the variable is used in a passthrough action, so it should be
+	// provided by the tool executing the output, like matchbox
+	VarRuntime = "runtime"
+)
+
+// Var is a value referenced by the templates of a file
+type Var struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// FileVars are the variables referenced by a file, imported with the given
+// values
+type FileVars struct {
+	File   string `json:"file"`
+	Values Values `json:"values,omitempty"`
+	Vars   []*Var `json:"vars"`
+
+	values   Values // all the values received by the file
+	resolved Values // the values after applying the defaults of the params
+	found    map[string]bool
+	all      bool // the templates use all the values, like {{ toJson . }}
+}
+
+// newFileVars returns the FileVars of a file, values are all the values
+// received by the file, explicit the ones given directly to it and resolved
+// the values after applying the defaults of the params
+func newFileVars(file string, values, explicit, resolved Values) *FileVars {
+	return &FileVars{
+		File:     file,
+		Values:   explicit,
+		Vars:     make([]*Var, 0),
+		values:   values,
+		resolved: resolved,
+		found:    make(map[string]bool),
+	}
+}
+
+// parse adds the variables referenced by the given template content, the
+// content of the file or any templated file included by it
+func (v *FileVars) parse(content []byte, o *options) error {
+	s, content, err := newSyntax(content)
+	if err != nil {
+		return err
+	}
+
+	src, _ := s.passthrough(content)
+	t, err := template.New(v.File).
+		Delims(s.Left, s.Right).
+		Funcs(o.funcs()).
+		Parse(string(src))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range templateVars(t) {
+		if name == allValues {
+			v.all = true
+			continue
+		}
+
+		status := VarMissing
+		if _, ok := v.Values[name]; ok {
+			status = VarProvided
+		} else if _, ok := v.values[name]; ok {
+			status = VarInherited
+		} else if _, ok := v.resolved[name]; ok {
+			status = VarDefault
+		}
+
+		v.add(name, status)
+	}
+
+	t, err = parseRuntime(v.File, s.passthroughActions(content))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range templateVars(t) {
+		if name != allValues {
+			v.add(name, VarRuntime)
+		}
+	}
+
+	return nil
+}
+
+// add adds a variable, if not present, keeping the runtime variables after
+// the rest, both sorted by name
+func (v *FileVars) add(name, status string) {
+	key := name
+	if status == VarRuntime {
+		key = "runtime:" + name
+	}
+
+	if v.found[key] {
+		return
+	}
+
+	v.found[key] = true
+	v.Vars = append(v.Vars, &Var{Name: name, Status: status})
+	sort.SliceStable(v.Vars, func(i, j int) bool {
+		ri, rj := v.Vars[i].Status == VarRuntime, v.Vars[j].Status == VarRuntime
+		if ri != rj {
+			return rj
+		}
+
+		return v.Vars[i].Name < v.Vars[j].Name
+	})
+}
+
+// ownUses returns the values used by the templates of the config, including
+// the conditions of its imports, rendered with the file
+func (c *Config) ownUses() map[string]bool {
+	uses := make(map[string]bool)
+	if len(c.vars) != 0 {
+		for _, v := range c.vars[0].Vars {
+			if v.Status != VarRuntime {
+				uses[v.Name] = true
+			}
+		}
+
+		if c.vars[0].all {
+			uses[allValues] = true
+		}
+	}
+
+	return uses
+}
+
+// checkUses adds to the report a warning for every value given in the import
+// not used by src, nor declared as param, unless src uses all its values, and
+// adds to the uses of the config the values inherited and used by src
+func (c *Config) checkUses(imp *Import, src *Config, fullpath string, res *resolution) {
+	declared := make(map[string]bool, len(src.Params))
+	for _, p := range src.Params {
+		declared[p.Name] = true
+	}
+
+	var names []string
+	for name := range imp.Values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if !src.uses[name] && !src.uses[allValues] && !declared[name] {
+			res.report.Add(report.Entry{
+				Kind: report.EntryWarning,
+				Message: fmt.Sprintf(
+					"value %q given to %q in %q is never used", name, fullpath, c.path(),
+				),
+			})
+		}
+	}
+
+	for name := range src.uses {
+		_, given := imp.Values[name]
+		if !given && !strings.HasPrefix(name, privatePrefix) {
+			c.uses[name] = true
+		}
+	}
+}
+
+// undefinedFunc matches the parse errors of the undefined functions
+var undefinedFunc = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// parseRuntime parses the passthrough actions, the functions are the ones of
+// the tool executing them, unknown here, so any undefined function is declared
+// as a placeholder
+func parseRuntime(name, text string) (*template.Template, error) {
+	placeholders := make(template.FuncMap)
+	for {
+		t, err := template.New(name).Funcs(placeholders).Parse(text)
+		if err == nil {
+			return t, nil
+		}
+
+		m := undefinedFunc.FindStringSubmatch(err.Error())
+		if m == nil || placeholders[m[1]] != nil {
+			return nil, err
+		}
+
+		placeholders[m[1]] = func(...interface{}) interface{} { return nil }
+	}
+}
+
+// allValues is the name used for the data of the template as a whole, like in
+// {{ toJson . }} or {{ template "foo" $ }}, meaning that all the values are used
+const allValues = "."
+
+// templateVars returns the sorted names of the values referenced in the
+// template, as fields of the data, like .foo, $.foo or index . "foo", and
+// allValues if the data is used as a whole
+func templateVars(t *template.Template) []string {
+	found := make(map[string]bool)
+	for _, t := range t.Templates() {
+		if t.Tree != nil {
+			walkVars(t.Tree.Root, true, found)
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// walkVars adds to found the values referenced by the node, dot is false
+// inside of range and with, where the dot is not the data of the template
+func walkVars(node parse.Node, dot bool, found map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, node := range n.Nodes {
+			walkVars(node, dot, found)
+		}
+	case *parse.ActionNode:
+		walkVars(n.Pipe, dot, found)
+	case *parse.TemplateNode:
+		walkVars(n.Pipe, dot, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, cmd := range n.Cmds {
+			walkVars(cmd, dot, found)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 3 && isIndexOfDot(n.Args) {
+			if s, ok := n.Args[2].(*parse.StringNode); ok && dot {
+				found[s.Text] = true
+			}
+
+			for i, arg := range n.Args {
+				if i != 1 {
+					walkVars(arg, dot, found)
+				}
+			}
+
+			return
+		}
+
+		for _, arg := range n.Args {
+			walkVars(arg, dot, found)
+		}
+	case *parse.DotNode:
+		if dot {
+			found[allValues] = true
+		}
+	case *parse.ChainNode:
+		walkVars(n.Node, dot, found)
+	case *parse.FieldNode:
+		if dot {
+			found[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			found[n.Ident[1]] = true
+		} else if n.Ident[0] == "$" {
+			found[allValues] = true
+		}
+	case *parse.IfNode:
+		walkVars(n.Pipe, dot, found)
+		walkVars(n.List, dot, found)
+		walkVars(n.ElseList, dot, found)
+	case *parse.RangeNode:
+		walkVars(n.Pipe, dot, found)
+		walkVars(n.List, false, found)
+		walkVars(n.ElseList, dot, found)
+	case *parse.WithNode:
+		walkVars(n.Pipe, dot, found)
+		walkVars(n.List, false, found)
+		walkVars(n.ElseList, dot, found)
+	}
+}
+
+// isIndexOfDot returns true if the command is like index . "foo"
+func isIndexOfDot(args []parse.Node) bool {
+	id, ok := args[0].(*parse.IdentifierNode)
+	if !ok || id.Ident != "index" {
+		return false
+	}
+
+	_, ok = args[1].(*parse.DotNode)
+	return ok
+}
@@ -0,0 +1,96 @@
+package combustion
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateVars(t *testing.T) {
+	tmpl, err := template.New("foo").Funcs(funcs).Parse("" +
+		`{{ .foo }} {{ .bar.baz | upper }} {{ index . "qux" | default "x" }}` +
+		`{{ range .list }}{{ .item }}{{ $.outer }}{{ end }}` +
+		`{{ with .map }}{{ .key }}{{ else }}{{ .other }}{{ end }}` +
+		`{{ if eq .cond "a" }}{{ .then }}{{ end }}`,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"bar", "cond", "foo", "list", "map", "other", "outer", "qux", "then",
+	}, templateVars(tmpl))
+
+	for _, text := range []string{
+		`{{ toJson . }}`,
+		`{{ template "foo" $ }}`,
+		`{{ range $k, $v := . }}{{ $k }}{{ end }}`,
+		`{{ range .list }}{{ toJson $ }}{{ end }}`,
+	} {
+		tmpl, err := template.New("foo").Funcs(funcs).Parse(text)
+		assert.NoError(t, err, text)
+		assert.Contains(t, templateVars(tmpl), allValues, text)
+	}
+
+	tmpl, err = template.New("foo").Funcs(funcs).Parse(
+		`{{ index . "foo" }}{{ range .list }}{{ . }}{{ end }}{{ with .map }}{{ toJson . }}{{ end }}`,
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo", "list", "map"}, templateVars(tmpl))
+}
+
+func TestParseRuntime(t *testing.T) {
+	tmpl, err := parseRuntime("foo", `{{ .foo | unknown "a" }}{{ other .bar }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bar", "foo"}, templateVars(tmpl))
+
+	_, err = parseRuntime("foo", `{{ .foo `)
+	assert.Error(t, err)
+}
+
+func TestConfigVars(t *testing.T) {
+	WriteFixtures([][]string{{
+		"fixtures/vars/foo.yaml",
+		"params:\n  - name: channel\n    default: stable\n" +
+			"import:\n  - file: bar.yaml\n    values:\n      action: reboot\n" +
+			"systemd:\n  units:\n   - name: foo {{ .channel }} {{ .name }}",
+	}, {
+		"fixtures/vars/bar.yaml",
+		"systemd:\n  units:\n   - name: bar {{ .action }} {{ .name }} {{ .missing }} {% .request %}",
+	}})
+
+	input := []byte("" +
+		"---\n" +
+		"import:\n" +
+		"  - vars/foo.yaml\n" +
+		"",
+	)
+
+	c, err := NewConfig(
+		bytes.NewBuffer(input), "fixtures/inline.yaml", Values{"name": "foo"},
+		AllowMissing(),
+	)
+
+	assert.NoError(t, err)
+
+	vars := c.Vars()
+	assert.Len(t, vars, 3)
+	assert.Equal(t, "fixtures/inline.yaml", vars[0].File)
+	assert.Len(t, vars[0].Vars, 0)
+
+	assert.Equal(t, "fixtures/vars/foo.yaml", vars[1].File)
+	assert.Equal(t, []*Var{
+		{Name: "channel", Status: VarDefault},
+		{Name: "name", Status: VarInherited},
+	}, vars[1].Vars)
+
+	assert.Equal(t, "fixtures/vars/bar.yaml", vars[2].File)
+	assert.Equal(t, Values{"action": "reboot"}, vars[2].Values)
+	assert.Equal(t, []*Var{
+		{Name: "action", Status: VarProvided},
+		{Name: "missing", Status: VarMissing},
+		{Name: "name", Status: VarInherited},
+		{Name: "request", Status: VarRuntime},
+	}, vars[2].Vars)
+}